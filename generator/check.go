@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"flo.znkr.io/generator/diag"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Checks that the site renders and doesn't contain secrets, without packing it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("determining workdir: %v", err)
+		}
+		cfg, err := loadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("loading config: %v", err)
+		}
+
+		var diags diag.List
+		s, err := load(dir, &diags)
+		if err != nil {
+			return fmt.Errorf("loading site: %v", err)
+		}
+		scanner := cfg.secretScanner()
+		for _, d := range s.AllDocs() {
+			b, err := s.RenderPage(d)
+			if err != nil {
+				diags.Add(d.Source, err)
+				continue
+			}
+			diags.Add(d.Source, scanner.Scan(d, b))
+		}
+
+		for _, d := range diags {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		if diags.Err() != nil {
+			return fmt.Errorf("check failed with %d diagnostics", len(diags))
+		}
+		return nil
+	},
+}
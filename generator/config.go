@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"flo.znkr.io/generator/secrets"
+)
+
+// configFile is the name of the optional config file in the root directory of the site.
+const configFile = "config.json"
+
+// config is the configuration of the site generator.
+type config struct {
+	Includes struct {
+		// Allow lists directories outside of the site tree that directives may access files
+		// in. Paths are relative to the root directory.
+		Allow []string `json:"allow"`
+	} `json:"includes"`
+
+	Redact struct {
+		// Rules are named redaction rules, directives refer to them with the redact-rules
+		// attribute. The rules are regular expressions, see [directives.Redactor].
+		Rules map[string]string `json:"rules"`
+	} `json:"redact"`
+
+	Secrets struct {
+		// Allow lists findings of the secret scanner that aren't secrets by the hash reported
+		// with the finding. If a file is set too, the finding is only allowed in that file of
+		// the packed site.
+		Allow []struct {
+			File string `json:"file"`
+			Hash string `json:"hash"`
+		} `json:"allow"`
+	} `json:"secrets"`
+
+	Output struct {
+		// Commands are the named commands include-output can run with the cmd attribute.
+		// Every command is a list of arguments, starting with the program.
+		Commands map[string][]string `json:"commands"`
+
+		// Unisolated allows include-output to run commands without network isolation on
+		// systems that don't support it, e.g. without unprivileged user namespaces.
+		Unisolated bool `json:"unisolated"`
+	} `json:"output"`
+}
+
+// loadConfig loads the config from the root directory dir. A missing config file is the same as an
+// empty config.
+func loadConfig(dir string) (*config, error) {
+	cfg := &config{}
+	b, err := os.ReadFile(filepath.Join(dir, configFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", configFile, err)
+	}
+	for i, p := range cfg.Includes.Allow {
+		if filepath.IsAbs(p) {
+			return nil, fmt.Errorf("%s: includes.allow: path must be relative: %s", configFile, p)
+		}
+		cfg.Includes.Allow[i] = filepath.Join(dir, p)
+	}
+	for i, a := range cfg.Secrets.Allow {
+		if a.Hash == "" {
+			return nil, fmt.Errorf("%s: secrets.allow[%d]: hash is required", configFile, i)
+		}
+	}
+	for name, args := range cfg.Output.Commands {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s: output.commands.%s: empty command", configFile, name)
+		}
+	}
+	return cfg, nil
+}
+
+// redactRules returns the compiled redaction rules.
+func (cfg *config) redactRules() (map[string]*regexp.Regexp, error) {
+	rules := make(map[string]*regexp.Regexp, len(cfg.Redact.Rules))
+	for name, pattern := range cfg.Redact.Rules {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: redact.rules.%s: %v", configFile, name, err)
+		}
+		rules[name] = re
+	}
+	return rules, nil
+}
+
+// secretScanner returns the secret scanner with the allowlist from the config.
+func (cfg *config) secretScanner() *secrets.Scanner {
+	var allow []secrets.Allow
+	for _, a := range cfg.Secrets.Allow {
+		allow = append(allow, secrets.Allow{File: a.File, Hash: a.Hash})
+	}
+	return secrets.NewScanner(allow...)
+}
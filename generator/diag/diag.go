@@ -0,0 +1,156 @@
+// Package diag provides positional diagnostics shared by all stages of the site generator.
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity describes how severe a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a message about a position in a source file.
+//
+// Line and Col are 1-based, a zero value means that the line or column is unknown.
+type Diagnostic struct {
+	File      string
+	Line, Col int
+	Severity  Severity
+	Msg       string
+}
+
+// Errorf creates a new error diagnostic.
+func Errorf(file string, line, col int, format string, args ...any) *Diagnostic {
+	return &Diagnostic{
+		File:     file,
+		Line:     line,
+		Col:      col,
+		Severity: Error,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+// Pos returns the position of the diagnostic in the file:line:col format used by compilers.
+func (d *Diagnostic) Pos() string {
+	var sb strings.Builder
+	sb.WriteString(d.File)
+	if d.Line > 0 {
+		fmt.Fprintf(&sb, ":%d", d.Line)
+		if d.Col > 0 {
+			fmt.Fprintf(&sb, ":%d", d.Col)
+		}
+	}
+	return sb.String()
+}
+
+func (d *Diagnostic) Error() string {
+	pos := d.Pos()
+	if pos == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, d.Severity, d.Msg)
+}
+
+// List is a list of diagnostics.
+type List []*Diagnostic
+
+// Add adds err to the list. If err is or wraps diagnostics, they are added as they are, otherwise
+// err is added as an error diagnostic in file without position. Diagnostics that are already in
+// the list are not added again.
+func (l *List) Add(file string, err error) {
+	var list List
+	var d *Diagnostic
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &list):
+		for _, d := range list {
+			l.add(d)
+		}
+	case errors.As(err, &d):
+		l.add(d)
+	default:
+		l.add(&Diagnostic{File: file, Severity: Error, Msg: err.Error()})
+	}
+}
+
+func (l *List) add(d *Diagnostic) {
+	for _, e := range *l {
+		if *e == *d {
+			return
+		}
+	}
+	*l = append(*l, d)
+}
+
+// Err returns the list as an error, or nil if it doesn't contain any error diagnostics.
+func (l List) Err() error {
+	for _, d := range l {
+		if d.Severity == Error {
+			return l
+		}
+	}
+	return nil
+}
+
+func (l List) Error() string {
+	var sb strings.Builder
+	for i, d := range l {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(d.Error())
+	}
+	return sb.String()
+}
+
+// Wrap returns err as a diagnostic in file if err isn't a diagnostic already. If err is a
+// diagnostic without a file, the file is set to file. The position of the diagnostic is shifted by
+// line-1 lines to account for inputs that start at line in file.
+//
+// Wrap returns nil if err is nil.
+func Wrap(file string, line int, err error) error {
+	if err == nil {
+		return nil
+	}
+	var list List
+	if errors.As(err, &list) {
+		for _, d := range list {
+			relocate(d, file, line)
+		}
+		return list
+	}
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		relocate(d, file, line)
+		return d
+	}
+	return &Diagnostic{File: file, Severity: Error, Msg: err.Error()}
+}
+
+func relocate(d *Diagnostic, file string, line int) {
+	if d.File != "" {
+		return
+	}
+	d.File = file
+	if d.Line > 0 && line > 1 {
+		d.Line += line - 1
+	}
+}
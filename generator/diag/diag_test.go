@@ -0,0 +1,89 @@
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiagnostic_Error(t *testing.T) {
+	tests := []struct {
+		d    *Diagnostic
+		want string
+	}{
+		{Errorf("a.md", 3, 4, "oops"), "a.md:3:4: error: oops"},
+		{Errorf("a.md", 3, 0, "oops"), "a.md:3: error: oops"},
+		{Errorf("a.md", 0, 0, "oops"), "a.md: error: oops"},
+		{Errorf("", 0, 0, "oops"), "error: oops"},
+		{&Diagnostic{File: "a.md", Line: 1, Severity: Warning, Msg: "hmm"}, "a.md:1: warning: hmm"},
+	}
+	for _, tt := range tests {
+		if got := tt.d.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: nil,
+		},
+		{
+			name: "plain_error",
+			err:  errors.New("oops"),
+			want: Errorf("a.md", 0, 0, "oops"),
+		},
+		{
+			name: "diagnostic",
+			err:  fmt.Errorf("wrapped: %w", Errorf("", 2, 3, "oops")),
+			want: Errorf("a.md", 11, 3, "oops"),
+		},
+		{
+			name: "diagnostic_with_file",
+			err:  Errorf("b.md", 2, 3, "oops"),
+			want: Errorf("b.md", 2, 3, "oops"),
+		},
+		{
+			name: "list",
+			err:  List{Errorf("", 1, 1, "one"), Errorf("", 0, 0, "two")},
+			want: List{Errorf("a.md", 10, 1, "one"), Errorf("a.md", 0, 0, "two")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap("a.md", 10, tt.err)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Wrap() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	var l List
+	if l.Err() != nil {
+		t.Errorf("empty list: Err() = %v, want nil", l.Err())
+	}
+
+	l.Add("a.md", &Diagnostic{File: "a.md", Severity: Warning, Msg: "hmm"})
+	if l.Err() != nil {
+		t.Errorf("list with warnings only: Err() = %v, want nil", l.Err())
+	}
+
+	l.Add("a.md", errors.New("oops"))
+	l.Add("a.md", fmt.Errorf("again: %w", Errorf("a.md", 0, 0, "oops")))
+	l.Add("b.md", List{Errorf("b.md", 1, 2, "one"), Errorf("b.md", 3, 4, "two")})
+	want := "a.md: warning: hmm\na.md: error: oops\nb.md:1:2: error: one\nb.md:3:4: error: two"
+	if l.Err() == nil || l.Err().Error() != want {
+		t.Errorf("Err() = %v, want %q", l.Err(), want)
+	}
+}
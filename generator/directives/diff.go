@@ -0,0 +1,353 @@
+package directives
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"flo.znkr.io/generator/highlight"
+	"flo.znkr.io/generator/site"
+	"znkr.io/diff"
+	"znkr.io/diff/textdiff"
+)
+
+// repoURL is the URL of the repository this site is developed in.
+const repoURL = "https://github.com/znkr/flo.znkr.io"
+
+// sourceURL returns the URL of a file in the site directory of the main branch.
+func sourceURL(path string) string {
+	return repoURL + "/tree/main/site" + path
+}
+
+// diffTable is the data for rendering a single diff table.
+type diffTable struct {
+	File  string
+	URL   string
+	Hunks []highlight.Hunk
+
+	// Headers reports whether hunk headers are shown and unchanged lines between hunks are
+	// folded.
+	Headers bool
+
+	// Context is the number of context lines used for folding, -1 if the diff isn't folded
+	// by context.
+	Context int
+
+	// Full reports whether the full file is shown, unchanged lines aren't folded in the browser.
+	Full bool
+
+	// Notes are shown below the lines they refer to.
+	Notes diffNotes
+}
+
+// diffAlgorithmAttrs are the attributes of the diff algorithm options, see [diffOptions].
+var diffAlgorithmAttrs = []Attr{
+	{Name: "indent-heuristic", Type: Bool},
+	{Name: "optimal", Type: Bool},
+	{Name: "fast", Type: Bool},
+	{Name: "strip-common", Type: Bool},
+}
+
+// diffSettingsAttrs are the attributes of the diff settings, see [parseDiffSettings].
+var diffSettingsAttrs = append([]Attr{
+	{Name: "context", Type: Int},
+	{Name: "layout"},
+	{Name: "lang"},
+}, diffAlgorithmAttrs...)
+
+var diffSchema = &Schema{
+	Attrs: append([]Attr{
+		{Name: "diff"},
+		{Name: "a"},
+		{Name: "b"},
+		{Name: "display"},
+		{Name: "notes"},
+		{Name: "notes-file"},
+	}, slices.Concat(diffSettingsAttrs, redactAttrs)...),
+	Rules: []Rule{
+		OneOf([]string{"diff"}, []string{"a", "b"}),
+		Exclusive("notes", "notes-file"),
+	},
+}
+
+// diffFiles returns the files or directories include-diff compares, or the diff it renders.
+func diffFiles(dir *Directive) []string {
+	return []string{dir.Attrs["diff"], dir.Attrs["a"], dir.Attrs["b"]}
+}
+
+// diffSettings are the attributes of a directive for computing and rendering diffs.
+type diffSettings struct {
+	split   bool               // side-by-side layout
+	context int                // number of context lines, -1 if the diff isn't folded by context
+	lang    highlight.Option   // nil to detect the language from file names
+	opts    []highlight.Option // diff algorithm options
+	redact  *Redactor          // nil if nothing is redacted
+}
+
+// parseDiffSettings parses the diff settings from the attributes of dir.
+func parseDiffSettings(dir *Directive) (diffSettings, error) {
+	ds := diffSettings{context: -1}
+	if v, ok := dir.Attrs["context"]; ok {
+		var err error
+		ds.context, err = strconv.Atoi(v)
+		if err != nil || ds.context < 0 {
+			return ds, fmt.Errorf("invalid context attribute: %q", v)
+		}
+	}
+
+	switch layout := cmp.Or(dir.Attrs["layout"], "unified"); layout {
+	case "unified":
+	case "split":
+		ds.split = true
+	default:
+		return ds, fmt.Errorf("invalid layout attribute: %q", layout)
+	}
+
+	if lang, ok := dir.Attrs["lang"]; ok {
+		ds.lang = highlight.Lang(lang)
+	}
+
+	var err error
+	ds.opts, err = diffOptions(dir)
+	return ds, err
+}
+
+func (r *Renderer) includeDiff(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	ds, err := parseDiffSettings(dir)
+	if err != nil {
+		return fmt.Errorf("include-diff: %v", err)
+	}
+	if ds.redact, err = r.redactor(dir); err != nil {
+		return fmt.Errorf("include-diff: %v", err)
+	}
+	notes, err := r.readNotes(doc, dir)
+	if err != nil {
+		return fmt.Errorf("include-diff: %v", err)
+	}
+
+	var tables []diffTable
+	if dir.HasAttr("diff") {
+		for _, attr := range []string{"context", "indent-heuristic", "optimal", "fast", "strip-common"} {
+			if dir.HasAttr(attr) {
+				return fmt.Errorf("include-diff: %s is only supported with a and b", attr)
+			}
+		}
+		raw, err := r.resolver.ReadFile(doc, dir.Attrs["diff"])
+		if err != nil {
+			return fmt.Errorf("include-diff: %v", err)
+		}
+		url := sourceURL(path.Join(doc.Path, dir.Attrs["diff"]))
+		if ds.redact != nil {
+			raw = ds.redact.Redact(raw)
+			if url, err = r.redactedURL(doc, dir.Attrs["diff"]); err != nil {
+				return fmt.Errorf("include-diff: %v", err)
+			}
+		}
+		files, err := highlight.ParseDiff(string(raw), ds.lang)
+		if err != nil {
+			return fmt.Errorf("include-diff: %s: %v", dir.Attrs["diff"], err)
+		}
+		if notes != nil && len(files) != 1 {
+			return fmt.Errorf("include-diff: notes are only supported for diffs of a single file")
+		}
+		for _, f := range files {
+			// The display name only applies if there's a single file, otherwise it's
+			// ambiguous.
+			display := cmp.Or(f.Name(), dir.Attrs["diff"])
+			if len(files) == 1 {
+				display = cmp.Or(dir.Attrs["display"], display)
+			}
+			tables = append(tables, diffTable{
+				File:    display,
+				URL:     url,
+				Hunks:   f.Hunks,
+				Headers: f.HasHunkHeaders,
+				Context: -1,
+			})
+		}
+	} else {
+		if r.isDir(doc, dir.Attrs["a"]) && r.isDir(doc, dir.Attrs["b"]) {
+			if dir.HasAttr("display") {
+				return fmt.Errorf("include-diff: display is not supported for directories")
+			}
+			if notes != nil {
+				return fmt.Errorf("include-diff: notes are not supported for directories")
+			}
+			if err := r.renderDiffTree(buf, doc, dir.Attrs["a"], dir.Attrs["b"], ds); err != nil {
+				return fmt.Errorf("include-diff: %v", err)
+			}
+			return nil
+		}
+		a, err := r.readDiffSource(doc, dir.Attrs["a"])
+		if err != nil {
+			return fmt.Errorf("include-diff: %v", err)
+		}
+		b, err := r.readDiffSource(doc, dir.Attrs["b"])
+		if err != nil {
+			return fmt.Errorf("include-diff: %v", err)
+		}
+		t, err := compareSources(a, b, ds)
+		if err != nil {
+			return fmt.Errorf("include-diff: %v", err)
+		}
+		t.File = cmp.Or(dir.Attrs["display"], t.File)
+		tables = append(tables, t)
+	}
+
+	for _, t := range tables {
+		if err := notes.check(t.Hunks); err != nil {
+			return fmt.Errorf("include-diff: %v", err)
+		}
+		t.Notes = notes
+		if err := r.renderDiffTable(buf, t, ds.split); err != nil {
+			return fmt.Errorf("rendering include-diff: %v", err)
+		}
+	}
+	return nil
+}
+
+// compareSources computes the diff table for a and b.
+func compareSources(a, b *diffSource, ds diffSettings) (diffTable, error) {
+	var err error
+	if a, err = redactSource(a, ds.redact); err != nil {
+		return diffTable{}, err
+	}
+	if b, err = redactSource(b, ds.redact); err != nil {
+		return diffTable{}, err
+	}
+	lang := ds.lang
+	if lang == nil {
+		lang = highlight.LangFromFilename(cmp.Or(b.Name, a.Name))
+	}
+	opts := append(slices.Clip(ds.opts), lang)
+	context := ds.context
+	var hunks []highlight.Hunk
+	if context >= 0 {
+		hunks, err = highlight.DiffHunks(string(a.Data), string(b.Data), context, opts...)
+		if err != nil {
+			return diffTable{}, err
+		}
+	} else {
+		edits, err := highlight.Diff(string(a.Data), string(b.Data), opts...)
+		if err != nil {
+			return diffTable{}, err
+		}
+		hunks = []highlight.Hunk{{Edits: edits}}
+	}
+	return diffTable{
+		File:    cmp.Or(b.Name, a.Name),
+		URL:     cmp.Or(b.URL, a.URL),
+		Hunks:   hunks,
+		Headers: context >= 0,
+		Context: context,
+	}, nil
+}
+
+// diffOptions returns the highlight options for the diff algorithm options of dir.
+func diffOptions(dir *Directive) ([]highlight.Option, error) {
+	indentHeuristic, err := dir.BoolAttr("indent-heuristic", true)
+	if err != nil {
+		return nil, err
+	}
+	optimal, err := dir.BoolAttr("optimal", false)
+	if err != nil {
+		return nil, err
+	}
+	fast, err := dir.BoolAttr("fast", false)
+	if err != nil {
+		return nil, err
+	}
+	stripCommon, err := dir.BoolAttr("strip-common", false)
+	if err != nil {
+		return nil, err
+	}
+	if optimal && fast {
+		return nil, fmt.Errorf("optimal and fast are mutually exclusive")
+	}
+
+	var dopts []diff.Option
+	if indentHeuristic {
+		dopts = append(dopts, textdiff.IndentHeuristic())
+	}
+	if optimal {
+		dopts = append(dopts, diff.Minimal())
+	}
+	if fast {
+		dopts = append(dopts, diff.Fast())
+	}
+	opts := []highlight.Option{highlight.DiffOptions(dopts...)}
+	if stripCommon {
+		opts = append(opts, highlight.StripCommon())
+	}
+	return opts, nil
+}
+
+// renderDiffTable renders t as a unified or, if split is true, as a side-by-side diff.
+func (r *Renderer) renderDiffTable(buf *bytes.Buffer, t diffTable, split bool) error {
+	if split {
+		return r.diffSplit.Execute(buf, struct {
+			File   string
+			URL    string
+			Blocks []splitBlock
+			Notes  diffNotes
+		}{
+			File:   t.File,
+			URL:    t.URL,
+			Blocks: splitDiff(t.Hunks, t.Headers),
+			Notes:  t.Notes,
+		})
+	}
+	return r.diff.Execute(buf, t)
+}
+
+// diffSource is a file compared by include-diff.
+type diffSource struct {
+	Name string // name for display and language detection, empty for /dev/null
+	URL  string // link to the file, empty for /dev/null
+	Path string // path of the copy of the file served by the site, empty if there's none
+	Data []byte
+}
+
+// readDiffSource reads the file name referenced by a directive in doc. The name is either a path
+// relative to the source of doc, /dev/null, or a git source of the form git:REV:PATH.
+func (r *Renderer) readDiffSource(doc *site.Doc, name string) (*diffSource, error) {
+	if name == "/dev/null" {
+		return &diffSource{}, nil
+	}
+	if rev, path, ok := gitSource(name); ok {
+		return readGitSource(r.resolver, filepath.Dir(doc.Source), rev, path)
+	}
+	p, err := r.resolver.Resolve(doc, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	sp, _ := r.resolver.sitePath(p)
+	return &diffSource{
+		Name: name,
+		URL:  sourceURL(path.Join(doc.Path, name)),
+		Path: sp,
+		Data: data,
+	}, nil
+}
+
+// isDir reports whether name, referenced by a directive in doc, is a directory.
+func (r *Renderer) isDir(doc *site.Doc, name string) bool {
+	if _, _, ok := gitSource(name); ok || name == "/dev/null" {
+		return false
+	}
+	p, err := r.resolver.Resolve(doc, name)
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
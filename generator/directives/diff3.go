@@ -0,0 +1,69 @@
+package directives
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+
+	"flo.znkr.io/generator/highlight"
+	"flo.znkr.io/generator/site"
+)
+
+var diff3Schema = &Schema{
+	Attrs: append([]Attr{
+		{Name: "base", Required: true},
+		{Name: "a", Required: true},
+		{Name: "b", Required: true},
+		{Name: "display"},
+		{Name: "lang"},
+		{Name: "label-base"},
+		{Name: "label-a"},
+		{Name: "label-b"},
+	}, diffAlgorithmAttrs...),
+}
+
+// includeDiff3 renders a three-way merge of a and b, both derived from base. Changes of only one
+// side are shown as a diff against base, conflicting changes are shown with conflict markers.
+func (r *Renderer) includeDiff3(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	var srcs [3]*diffSource
+	for i, attr := range []string{"base", "a", "b"} {
+		var err error
+		srcs[i], err = r.readDiffSource(doc, dir.Attrs[attr])
+		if err != nil {
+			return fmt.Errorf("include-diff3: %v", err)
+		}
+	}
+	base, a, b := srcs[0], srcs[1], srcs[2]
+
+	opts, err := diffOptions(dir)
+	if err != nil {
+		return fmt.Errorf("include-diff3: %v", err)
+	}
+	if lang, ok := dir.Attrs["lang"]; ok {
+		opts = append(opts, highlight.Lang(lang))
+	} else {
+		opts = append(opts, highlight.LangFromFilename(cmp.Or(base.Name, a.Name, b.Name)))
+	}
+	chunks, err := highlight.Merge(string(base.Data), string(a.Data), string(b.Data), opts...)
+	if err != nil {
+		return fmt.Errorf("include-diff3: %v", err)
+	}
+
+	err = r.diff3.Execute(buf, struct {
+		File                      string
+		URL                       string
+		LabelBase, LabelA, LabelB string
+		Chunks                    []highlight.MergeChunk
+	}{
+		File:      cmp.Or(dir.Attrs["display"], base.Name, a.Name, b.Name),
+		URL:       cmp.Or(base.URL, a.URL, b.URL),
+		LabelBase: cmp.Or(dir.Attrs["label-base"], "base"),
+		LabelA:    cmp.Or(dir.Attrs["label-a"], "a"),
+		LabelB:    cmp.Or(dir.Attrs["label-b"], "b"),
+		Chunks:    chunks,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering include-diff3: %v", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -29,7 +30,9 @@ func (err *SyntaxError) Error() string {
 	return fmt.Sprintf("%s [%d:%d]", err.Msg, err.Line, err.Col)
 }
 
-func Parse(in []byte) (_ []Directive, err error) {
+// ParseDirective parses the directive at the start of in. Input after the end of the directive is
+// ignored, the End of the directive is the offset of the first byte after it.
+func ParseDirective(in []byte) (_ Directive, err error) {
 	defer func() {
 		if e := recover(); e != nil {
 			if e, ok := e.(*SyntaxError); ok {
@@ -41,18 +44,14 @@ func Parse(in []byte) (_ []Directive, err error) {
 	}()
 
 	p := parser{
-		in: in,
+		in:   in,
+		line: 1,
 	}
-
-	var dirs []Directive
-	for {
-		dir, ok := p.parseNextDirective()
-		if !ok {
-			break
-		}
-		dirs = append(dirs, dir)
+	p.next()
+	if !p.consume("<!--#") {
+		p.errorf("unexpected %q, expected '<!--#'", p.ch)
 	}
-	return dirs, nil
+	return p.parseDirective(0), nil
 }
 
 type parser struct {
@@ -62,48 +61,37 @@ type parser struct {
 	chw int
 
 	pos       int
-	col, line int
+	col, line int // 1-based
 	err       error
 }
 
-func (p *parser) parseNextDirective() (Directive, bool) {
+// parseDirective parses the rest of a directive that started at pos, after the opening "<!--#".
+func (p *parser) parseDirective(pos int) Directive {
+	d := Directive{}
+	d.Pos = pos
+
+	p.consumeSpaces()
+	d.Name = p.parseIdent()
+
+	d.Attrs = make(map[string]string)
 	for {
-		p.next()
-		if p.ch == eof {
+		p.consumeSpaces()
+		if !unicode.IsLetter(p.ch) {
 			break
 		}
-
-		pos := p.pos
-		if p.ch == '<' && p.consume("<!--#") {
-			d := Directive{}
-			d.Pos = pos
-
-			p.consumeSpaces()
-			d.Name = p.parseIdent()
-
-			d.Attrs = make(map[string]string)
-			for {
-				p.consumeSpaces()
-				if !unicode.IsLetter(p.ch) {
-					break
-				}
-				attr := p.parseIdent()
-				if !p.consume("=") {
-					p.errorf("unexpected %q, expected '='", p.ch)
-				}
-				value := p.parseValue()
-				d.Attrs[attr] = value
-			}
-
-			if !p.consume("-->") {
-				p.errorf("unexpected %q, expected '-->'", p.ch)
-			}
-			d.End = p.pos
-			return d, true
+		attr := p.parseIdent()
+		if !p.consume("=") {
+			p.errorf("unexpected %q, expected '='", p.ch)
 		}
+		value := p.parseValue()
+		d.Attrs[attr] = value
 	}
 
-	return Directive{}, false
+	if !p.consume("-->") {
+		p.errorf("unexpected %q, expected '-->'", p.ch)
+	}
+	d.End = p.pos
+	return d
 }
 
 func (p *parser) errorf(format string, args ...any) {
@@ -116,6 +104,10 @@ func (p *parser) errorf(format string, args ...any) {
 }
 
 func (p *parser) next() {
+	if p.ch == '\n' {
+		p.line++
+		p.col = 0
+	}
 	p.pos += p.chw
 	if p.pos >= len(p.in) {
 		p.ch = eof
@@ -126,10 +118,6 @@ func (p *parser) next() {
 	if p.ch == utf8.RuneError {
 		p.errorf("invalid UTF-8 [%d:%d]", p.line, p.col)
 	}
-	if p.ch == '\n' {
-		p.line++
-		p.col = 0
-	}
 	p.col++
 }
 
@@ -213,3 +201,16 @@ func (d *Directive) HasAttr(name string) bool {
 	_, ok := d.Attrs[name]
 	return ok
 }
+
+// BoolAttr returns the value of the boolean attribute name, or def if the attribute isn't set.
+func (d *Directive) BoolAttr(name string, def bool) (bool, error) {
+	v, ok := d.Attrs[name]
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s attribute: %q", name, v)
+	}
+	return b, nil
+}
@@ -7,120 +7,66 @@ import (
 	"github.com/google/go-cmp/cmp"
 )
 
-func TestParse(t *testing.T) {
+func TestParseDirective(t *testing.T) {
 	tests := []struct {
 		name string
 		in   string
-		want []Directive
+		want Directive
 	}{
-		{
-			name: "no_directives",
-			in:   "there\nare\nno\ndirectives\nhere",
-			want: nil,
-		},
-		{
-			name: "empty_input",
-			in:   "",
-			want: nil,
-		},
-		{
-			name: "only_whitespace",
-			in:   "   \n\t\n   ",
-			want: nil,
-		},
-		{
-			name: "partial_comment_not_directive",
-			in:   "<!-- this is a regular comment -->",
-			want: nil,
-		},
-		{
-			name: "multiple_directives",
-			in: `first <!--#inline-snippet file="a.go" -->
-second <!--#inline-snippet file="b.go" -->`,
-			want: []Directive{
-				{
-					Pos:  6,
-					End:  41,
-					Name: "inline-snippet",
-					Attrs: map[string]string{
-						"file": "a.go",
-					},
-				},
-				{
-					Pos:  49,
-					End:  84,
-					Name: "inline-snippet",
-					Attrs: map[string]string{
-						"file": "b.go",
-					},
-				},
-			},
-		},
 		{
 			name: "directive_with_multiple_attrs",
 			in:   `<!--#include-snippet file="test.go" lines="1..10" lang="go" -->`,
-			want: []Directive{
-				{
-					Pos:  0,
-					End:  63,
-					Name: "include-snippet",
-					Attrs: map[string]string{
-						"file":  "test.go",
-						"lines": "1..10",
-						"lang":  "go",
-					},
+			want: Directive{
+				Pos:  0,
+				End:  63,
+				Name: "include-snippet",
+				Attrs: map[string]string{
+					"file":  "test.go",
+					"lines": "1..10",
+					"lang":  "go",
 				},
 			},
 		},
 		{
 			name: "directive_with_hyphenated_name",
 			in:   `<!--#include-diff a="old.go" b="new.go" -->`,
-			want: []Directive{
-				{
-					Pos:  0,
-					End:  43,
-					Name: "include-diff",
-					Attrs: map[string]string{
-						"a": "old.go",
-						"b": "new.go",
-					},
+			want: Directive{
+				Pos:  0,
+				End:  43,
+				Name: "include-diff",
+				Attrs: map[string]string{
+					"a": "old.go",
+					"b": "new.go",
 				},
 			},
 		},
 		{
 			name: "directive_with_empty_attr_value",
 			in:   `<!--#test attr="" -->`,
-			want: []Directive{
-				{
-					Pos:  0,
-					End:  21,
-					Name: "test",
-					Attrs: map[string]string{
-						"attr": "",
-					},
+			want: Directive{
+				Pos:  0,
+				End:  21,
+				Name: "test",
+				Attrs: map[string]string{
+					"attr": "",
 				},
 			},
 		},
 		{
-			name: "inline-snippet-directive",
-			in: `there's an inline directive in here
-				 <!--#inline-snippet file="my/file.go" -->
-				`,
-			want: []Directive{
-				{
-					Pos:  41,
-					End:  82,
-					Name: "inline-snippet",
-					Attrs: map[string]string{
-						"file": "my/file.go",
-					},
+			name: "rest_of_input_ignored",
+			in:   "<!--#test a=\"1\" -->\nrest <!--#other -->",
+			want: Directive{
+				Pos:  0,
+				End:  19,
+				Name: "test",
+				Attrs: map[string]string{
+					"a": "1",
 				},
 			},
 		},
 		{
 			name: "metadata",
-			in: `# Some markdown
-			<!--#meta
+			in: `<!--#meta
 				published="1234-56-78"
 				summary="""
 					multiple
@@ -128,15 +74,13 @@ second <!--#inline-snippet file="b.go" -->`,
 					"""
 			-->
 			`,
-			want: []Directive{
-				{
-					Pos:  19,
-					End:  112,
-					Name: "meta",
-					Attrs: map[string]string{
-						"published": "1234-56-78",
-						"summary":   "\nmultiple\nlines\n",
-					},
+			want: Directive{
+				Pos:  0,
+				End:  93,
+				Name: "meta",
+				Attrs: map[string]string{
+					"published": "1234-56-78",
+					"summary":   "\nmultiple\nlines\n",
 				},
 			},
 		},
@@ -144,7 +88,7 @@ second <!--#inline-snippet file="b.go" -->`,
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := Parse([]byte(tt.in))
+			got, err := ParseDirective([]byte(tt.in))
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -155,7 +99,7 @@ second <!--#inline-snippet file="b.go" -->`,
 	}
 }
 
-func TestParse_Errors(t *testing.T) {
+func TestParseDirective_Errors(t *testing.T) {
 	tests := []struct {
 		name    string
 		in      string
@@ -191,11 +135,16 @@ func TestParse_Errors(t *testing.T) {
 			in:      `<!--#test attr="value"`,
 			wantErr: "expected '-->'",
 		},
+		{
+			name:    "not_a_directive",
+			in:      `text <!--#test -->`,
+			wantErr: "expected '<!--#'",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := Parse([]byte(tt.in))
+			_, err := ParseDirective([]byte(tt.in))
 			if err == nil {
 				t.Fatalf("expected error containing %q, got nil", tt.wantErr)
 			}
@@ -210,6 +159,46 @@ func TestParse_Errors(t *testing.T) {
 	}
 }
 
+func TestParseDirective_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		line, col int
+	}{
+		{
+			name: "first_line",
+			in:   `<!--#test attr=value -->`,
+			line: 1,
+			col:  16,
+		},
+		{
+			name: "later_line",
+			in:   "<!--#test\n\n  attr=value -->",
+			line: 3,
+			col:  8,
+		},
+		{
+			name: "multi_line_directive",
+			in:   "<!--#test\n\tattr=value\n-->",
+			line: 2,
+			col:  7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDirective([]byte(tt.in))
+			syntaxErr, ok := err.(*SyntaxError)
+			if !ok {
+				t.Fatalf("expected *SyntaxError, got %T", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Col != tt.col {
+				t.Errorf("position = [%d:%d], want [%d:%d]", syntaxErr.Line, syntaxErr.Col, tt.line, tt.col)
+			}
+		})
+	}
+}
+
 func TestHasAttr(t *testing.T) {
 	d := Directive{
 		Attrs: map[string]string{
@@ -0,0 +1,70 @@
+package directives
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitSource parses a source of the form git:REV:PATH.
+func gitSource(name string) (rev, path string, ok bool) {
+	rest, ok := strings.CutPrefix(name, "git:")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// readGitSource reads the file at path in revision rev of the git repository containing dir.
+//
+// The path is relative to the root of the repository, unless it starts with ./ or ../, then it's
+// relative to dir. The path in the working tree must be allowed by res. The returned source links
+// to the file at the resolved commit.
+func readGitSource(res *Resolver, dir, rev, name string) (*diffSource, error) {
+	out, err := git(dir, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	commit := strings.TrimSpace(string(out))
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		prefix, err := git(dir, "rev-parse", "--show-prefix")
+		if err != nil {
+			return nil, err
+		}
+		name = path.Join(strings.TrimSpace(string(prefix)), name)
+	}
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	if err := res.check(name, filepath.Join(strings.TrimSpace(string(top)), filepath.FromSlash(path.Clean(name)))); err != nil {
+		return nil, err
+	}
+	data, err := git(dir, "show", commit+":"+name)
+	if err != nil {
+		return nil, err
+	}
+	return &diffSource{
+		Name: name,
+		URL:  fmt.Sprintf("%s/blob/%s/%s", repoURL, commit, name),
+		Data: data,
+	}, nil
+}
+
+// git runs git with args in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package directives
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sub", "a.go"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	run("config", "user.email", "test@example.com")
+	run("config", "user.name", "Test")
+	write("package a\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "first")
+	write("package a // changed\n")
+	run("commit", "-q", "-a", "-m", "second")
+
+	res, err := NewResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev, path string
+		wantName  string
+		wantData  string
+	}{
+		{"HEAD~1", "sub/a.go", "sub/a.go", "package a\n"},
+		{"HEAD", "sub/a.go", "sub/a.go", "package a // changed\n"},
+	}
+	for _, tt := range tests {
+		src, err := readGitSource(res, dir, tt.rev, tt.path)
+		if err != nil {
+			t.Errorf("readGitSource(%q, %q): unexpected error: %v", tt.rev, tt.path, err)
+			continue
+		}
+		if src.Name != tt.wantName || string(src.Data) != tt.wantData {
+			t.Errorf("readGitSource(%q, %q) = %q, %q, want %q, %q", tt.rev, tt.path, src.Name, src.Data, tt.wantName, tt.wantData)
+		}
+		if !strings.Contains(src.URL, "/blob/") || strings.Contains(src.URL, tt.rev) {
+			t.Errorf("readGitSource(%q, %q): URL %q doesn't link to the commit", tt.rev, tt.path, src.URL)
+		}
+	}
+
+	// Paths starting with ./ are relative to dir.
+	src, err := readGitSource(res, filepath.Join(dir, "sub"), "HEAD", "./a.go")
+	if err != nil {
+		t.Fatalf("readGitSource(./a.go): unexpected error: %v", err)
+	}
+	if src.Name != "sub/a.go" {
+		t.Errorf("readGitSource(./a.go): name = %q, want sub/a.go", src.Name)
+	}
+
+	for _, tt := range []struct{ rev, path string }{{"nope", "sub/a.go"}, {"HEAD", "missing.go"}} {
+		if _, err := readGitSource(res, dir, tt.rev, tt.path); err == nil {
+			t.Errorf("readGitSource(%q, %q): expected error", tt.rev, tt.path)
+		}
+	}
+
+	// Paths outside of the site tree are rejected, even if they exist in the repository.
+	if err := os.Mkdir(filepath.Join(dir, "site"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	res, err = NewResolver(filepath.Join(dir, "site"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readGitSource(res, dir, "HEAD", "sub/a.go"); err == nil || !strings.Contains(err.Error(), "outside of the site tree") {
+		t.Errorf("readGitSource(sub/a.go) = %v, want error outside of the site tree", err)
+	}
+}
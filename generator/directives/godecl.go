@@ -0,0 +1,131 @@
+package directives
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+
+	"flo.znkr.io/generator/site"
+)
+
+var goDeclSchema = &Schema{
+	Attrs: []Attr{
+		{Name: "file", Required: true},
+		{Name: "decl", Required: true},
+		{Name: "doc", Type: Bool},
+		{Name: "elide"},
+		{Name: "emphasize"},
+		{Name: "display"},
+	},
+}
+
+func (r *Renderer) includeGoDecl(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	file := dir.Attrs["file"]
+	name := dir.Attrs["decl"]
+	withDoc, err := dir.BoolAttr("doc", true)
+	if err != nil {
+		return fmt.Errorf("include-go-decl: %v", err)
+	}
+
+	b, err := r.resolver.ReadFile(doc, file)
+	if err != nil {
+		return fmt.Errorf("include-go-decl: %v", err)
+	}
+	from, to, err := findGoDecl(file, b, name, withDoc)
+	if err != nil {
+		return fmt.Errorf("include-go-decl: %v", err)
+	}
+
+	return r.renderSnippet(buf, doc, dir, file, b, []lineRange{{from, to}})
+}
+
+// findGoDecl finds the declaration name in the Go source src and returns the lines it spans.
+//
+// The name is either the name of a function, a type, a constant or a variable, or a method name
+// qualified by the receiver type (T.M). For constants and variables, the whole declaration block is
+// returned. If withDoc is true, the doc comment is part of the returned lines.
+func findGoDecl(filename string, src []byte, name string, withDoc bool) (from, to int, err error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, filename, src, goparser.ParseComments|goparser.SkipObjectResolution)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	recv, fname, isMethod := strings.Cut(name, ".")
+	if !isMethod {
+		fname = name
+	}
+
+	span := func(doc *ast.CommentGroup, node ast.Node) (int, int, error) {
+		pos := node.Pos()
+		if withDoc && doc != nil {
+			pos = doc.Pos()
+		}
+		return fset.Position(pos).Line, fset.Position(node.End()).Line, nil
+	}
+
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Name.Name != fname || (decl.Recv != nil) != isMethod {
+				continue
+			}
+			if isMethod && recvTypeName(decl.Recv) != recv {
+				continue
+			}
+			return span(decl.Doc, decl)
+
+		case *ast.GenDecl:
+			if isMethod {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.Name != name {
+						continue
+					}
+					if decl.Lparen.IsValid() {
+						// Only return the type itself if it's part of a group.
+						return span(spec.Doc, spec)
+					}
+					return span(decl.Doc, decl)
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						if ident.Name == name {
+							return span(decl.Doc, decl)
+						}
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("declaration %q not found in %s", name, filename)
+}
+
+// recvTypeName returns the name of the receiver type, without pointers or type parameters.
+func recvTypeName(recv *ast.FieldList) string {
+	if recv == nil || len(recv.List) == 0 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
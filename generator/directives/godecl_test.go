@@ -0,0 +1,88 @@
+package directives
+
+import (
+	"strings"
+	"testing"
+)
+
+const goDeclSrc = `package sort
+
+import "cmp"
+
+// Limit is the limit.
+const Limit = 12
+
+// Modes.
+const (
+	A = iota
+	B
+)
+
+type (
+	// Pair is a pair.
+	Pair[T any] struct {
+		a, b T
+	}
+
+	Other int
+)
+
+// Slice is a slice.
+type Slice []int
+
+// Sort sorts.
+func Sort[T cmp.Ordered](s []T) {
+	_ = s
+}
+
+// Len returns the length.
+func (s Slice) Len() int { return len(s) }
+
+// Swap swaps.
+func (p *Pair[T]) Swap() {
+	p.a, p.b = p.b, p.a
+}
+`
+
+func TestFindGoDecl(t *testing.T) {
+	tests := []struct {
+		name     string
+		withDoc  bool
+		from, to int
+	}{
+		{"Limit", true, 5, 6},
+		{"Limit", false, 6, 6},
+		{"B", true, 8, 12},
+		{"Pair", true, 15, 18},
+		{"Pair", false, 16, 18},
+		{"Other", true, 20, 20},
+		{"Slice", true, 23, 24},
+		{"Sort", true, 26, 29},
+		{"Sort", false, 27, 29},
+		{"Slice.Len", true, 31, 32},
+		{"Pair.Swap", true, 34, 37},
+	}
+	for _, tt := range tests {
+		from, to, err := findGoDecl("sort.go", []byte(goDeclSrc), tt.name, tt.withDoc)
+		if err != nil {
+			t.Errorf("findGoDecl(%q, %v): unexpected error: %v", tt.name, tt.withDoc, err)
+			continue
+		}
+		if from != tt.from || to != tt.to {
+			t.Errorf("findGoDecl(%q, %v) = %d..%d, want %d..%d", tt.name, tt.withDoc, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+func TestFindGoDecl_Errors(t *testing.T) {
+	for _, name := range []string{"Missing", "Len", "Sort.Len", "Pair.Len", "Slice.Swap"} {
+		_, _, err := findGoDecl("sort.go", []byte(goDeclSrc), name, true)
+		if err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("findGoDecl(%q): error = %v, want not found error", name, err)
+		}
+	}
+
+	if _, _, err := findGoDecl("broken.go", []byte("package"), "X", true); err == nil {
+		t.Errorf("findGoDecl on invalid source: expected error")
+	}
+}
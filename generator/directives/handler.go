@@ -0,0 +1,122 @@
+package directives
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"slices"
+	"sync"
+
+	"flo.znkr.io/generator/site"
+)
+
+// Handler implements a directive.
+type Handler interface {
+	// Schema returns the attribute schema of the directive. Directives are validated against it
+	// before Render is called.
+	Schema() *Schema
+
+	// Render renders the directive dir found in doc into buf.
+	Render(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error
+}
+
+// FileIncluder is implemented by handlers of directives that include files. If such a directive
+// redacts the files, the site serves its copies of the files redacted the same way, see
+// [Renderer.Redactions].
+type FileIncluder interface {
+	// IncludedFiles returns the names of the files included by dir, relative to the source of
+	// the doc dir is found in.
+	IncludedFiles(dir *Directive) []string
+}
+
+// HandlerFactory creates a handler for a renderer. Handlers can use the templates of the site to
+// render their output. They must access files only through the resolver, so that directives can't
+// read files outside of the site tree.
+type HandlerFactory func(templates *template.Template, resolver *Resolver) Handler
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]HandlerFactory)
+)
+
+// Register registers the handler factory for the directive name. It's meant to be called from init
+// functions of packages implementing directives. Register panics if a directive with the same
+// name is already registered.
+func Register(name string, factory HandlerFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := builtins[name]; ok {
+		panic(fmt.Sprintf("directives: directive %s is already registered", name))
+	}
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("directives: directive %s is already registered", name))
+	}
+	registry[name] = factory
+}
+
+// builtin is a directive implemented by the renderer itself.
+type builtin struct {
+	schema *Schema
+	render func(r *Renderer, buf *bytes.Buffer, doc *site.Doc, dir *Directive) error
+	files  func(dir *Directive) []string // implements [FileIncluder] if not nil
+}
+
+// builtins are the directives implemented in this package.
+var builtins = map[string]builtin{
+	"include-snippet": {snippetSchema, (*Renderer).includeSnippet, snippetFiles},
+	"include-diff":    {diffSchema, (*Renderer).includeDiff, diffFiles},
+	"include-diff3":   {diff3Schema, (*Renderer).includeDiff3, nil},
+	"include-go-decl": {goDeclSchema, (*Renderer).includeGoDecl, nil},
+	"include-steps":   {stepsSchema, (*Renderer).includeSteps, nil},
+	"include-output":  {outputSchema, (*Renderer).includeOutput, nil},
+}
+
+// builtinHandler binds a builtin directive to a renderer.
+type builtinHandler struct {
+	r *Renderer
+	b builtin
+}
+
+func (h *builtinHandler) Schema() *Schema { return h.b.schema }
+
+func (h *builtinHandler) Render(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	return h.b.render(h.r, buf, doc, dir)
+}
+
+// includingHandler is a builtin handler of a directive that includes files.
+type includingHandler struct {
+	builtinHandler
+}
+
+func (h *includingHandler) IncludedFiles(dir *Directive) []string { return h.b.files(dir) }
+
+// newHandlers returns the handlers of all builtin and registered directives for r.
+func (r *Renderer) newHandlers(templates *template.Template) map[string]Handler {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	handlers := make(map[string]Handler, len(builtins)+len(registry))
+	for name, b := range builtins {
+		if b.files != nil {
+			handlers[name] = &includingHandler{builtinHandler{r, b}}
+		} else {
+			handlers[name] = &builtinHandler{r, b}
+		}
+	}
+	for name, factory := range registry {
+		handlers[name] = factory(templates, r.resolver)
+	}
+	return handlers
+}
+
+// unknownDirective returns the error for the unknown directive name.
+func (r *Renderer) unknownDirective(name string) error {
+	var names []string
+	for n := range r.handlers {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	if sug := suggest(name, names); sug != "" {
+		return fmt.Errorf("unknown directive: %s, did you mean %s?", name, sug)
+	}
+	return fmt.Errorf("unknown directive: %s", name)
+}
@@ -0,0 +1,88 @@
+package directives
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"flo.znkr.io/generator/highlight"
+)
+
+// lineRange is an inclusive range of 1-based line numbers.
+type lineRange struct {
+	from, to int
+}
+
+// parseLineRanges parses a comma separated list of line ranges. Every range is either a single line
+// number n, or a range from..to where from and to are optional and default to the first and last
+// line respectively.
+func parseLineRanges(sel string) ([]lineRange, error) {
+	var ret []lineRange
+	for part := range strings.SplitSeq(sel, ",") {
+		part = strings.TrimSpace(part)
+		r := lineRange{1, math.MaxInt}
+		from, to, isRange := strings.Cut(part, "..")
+		if !isRange {
+			to = from
+		}
+		if from == "" && to == "" {
+			return nil, fmt.Errorf("invalid line range %q", part)
+		}
+		if from != "" {
+			i, err := strconv.Atoi(from)
+			if err != nil || i < 1 {
+				return nil, fmt.Errorf("invalid line range %q", part)
+			}
+			r.from = i
+		}
+		if to != "" {
+			i, err := strconv.Atoi(to)
+			if err != nil || i < 1 {
+				return nil, fmt.Errorf("invalid line range %q", part)
+			}
+			r.to = i
+		}
+		if r.from > r.to {
+			return nil, fmt.Errorf("invalid line range %q: start after end", part)
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+func inRanges(lineNo int, ranges []lineRange) bool {
+	for _, r := range ranges {
+		if r.from <= lineNo && lineNo <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// snippetLine is a line of a snippet as rendered by the include_snippet template.
+type snippetLine struct {
+	highlight.Line
+	Elision    bool // true if the line stands for lines that have been left out
+	Emphasized bool
+}
+
+// selectLines returns all lines that are in one of the ranges in include (or all lines if include is
+// empty) and in none of the ranges in exclude. An elision line is inserted wherever lines have been
+// left out between two selected lines.
+func selectLines(lines []highlight.Line, include, exclude []lineRange) []snippetLine {
+	var ret []snippetLine
+	skipped := false
+	for _, l := range lines {
+		if len(include) > 0 && !inRanges(l.LineNo, include) || inRanges(l.LineNo, exclude) {
+			skipped = true
+			continue
+		}
+		if skipped && len(ret) > 0 {
+			ret = append(ret, snippetLine{Elision: true})
+		}
+		skipped = false
+		ret = append(ret, snippetLine{Line: l})
+	}
+	return ret
+}
@@ -0,0 +1,101 @@
+package directives
+
+import (
+	"math"
+	"testing"
+
+	"flo.znkr.io/generator/highlight"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []lineRange
+	}{
+		{"1..4", []lineRange{{1, 4}}},
+		{"7", []lineRange{{7, 7}}},
+		{"..4", []lineRange{{1, 4}}},
+		{"40..", []lineRange{{40, math.MaxInt}}},
+		{"1..4,20..31,40..", []lineRange{{1, 4}, {20, 31}, {40, math.MaxInt}}},
+		{"1..4, 7", []lineRange{{1, 4}, {7, 7}}},
+	}
+	for _, tt := range tests {
+		got, err := parseLineRanges(tt.in)
+		if err != nil {
+			t.Errorf("parseLineRanges(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(lineRange{})); diff != "" {
+			t.Errorf("parseLineRanges(%q) mismatch (-want +got):\n%s", tt.in, diff)
+		}
+	}
+
+	for _, in := range []string{"", "..", "a..4", "1..b", "4..1", "0..2", "1..4,", "-1"} {
+		if _, err := parseLineRanges(in); err == nil {
+			t.Errorf("parseLineRanges(%q): expected error", in)
+		}
+	}
+}
+
+func TestSelectLines(t *testing.T) {
+	var lines []highlight.Line
+	for i := 1; i <= 10; i++ {
+		if i == 5 {
+			continue // e.g. a removed region marker
+		}
+		lines = append(lines, highlight.Line{LineNo: i})
+	}
+
+	tests := []struct {
+		name             string
+		include, exclude []lineRange
+		want             []int // 0 is an elision
+	}{
+		{
+			name: "all",
+			want: []int{1, 2, 3, 4, 6, 7, 8, 9, 10},
+		},
+		{
+			name:    "adjacent_ranges",
+			include: []lineRange{{1, 2}, {3, 4}},
+			want:    []int{1, 2, 3, 4},
+		},
+		{
+			name:    "ranges_with_gap",
+			include: []lineRange{{1, 2}, {7, math.MaxInt}},
+			want:    []int{1, 2, 0, 7, 8, 9, 10},
+		},
+		{
+			name:    "no_elision_at_start_or_end",
+			include: []lineRange{{3, 4}},
+			want:    []int{3, 4},
+		},
+		{
+			name:    "elide",
+			exclude: []lineRange{{3, 8}},
+			want:    []int{1, 2, 0, 9, 10},
+		},
+		{
+			name:    "lines_and_elide",
+			include: []lineRange{{2, 9}},
+			exclude: []lineRange{{3, 3}, {7, 8}},
+			want:    []int{2, 0, 4, 6, 0, 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, l := range selectLines(lines, tt.include, tt.exclude) {
+				if l.Elision {
+					got = append(got, 0)
+				} else {
+					got = append(got, l.LineNo)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("selectLines() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package directives
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"flo.znkr.io/generator/highlight"
+	"flo.znkr.io/generator/site"
+	"github.com/yuin/goldmark"
+	"znkr.io/diff"
+)
+
+// diffNotes are review-style notes on the lines of a diff, keyed by line reference: +N refers to
+// line N of the new file, -N to line N of the old file.
+type diffNotes map[string][]template.HTML
+
+var noteRefRe = regexp.MustCompile(`^([+-][0-9]+):\s?(.*)$`)
+
+// readNotes reads the notes of a diff directive, either from the notes attribute or from the file
+// referenced by the notes-file attribute. It returns nil if the directive has no notes.
+func (r *Renderer) readNotes(doc *site.Doc, dir *Directive) (diffNotes, error) {
+	var src string
+	switch {
+	case dir.HasAttr("notes"):
+		src = dir.Attrs["notes"]
+	case dir.HasAttr("notes-file"):
+		b, err := r.resolver.ReadFile(doc, dir.Attrs["notes-file"])
+		if err != nil {
+			return nil, err
+		}
+		src = string(b)
+	default:
+		return nil, nil
+	}
+	return parseNotes(src)
+}
+
+// parseNotes parses notes. Every note starts with a line reference followed by a colon and
+// markdown, e.g.
+//
+//	+12: This is **new**.
+//	-7: This line is removed,
+//	    because it's not used anymore.
+//
+// Lines without a line reference continue the previous note. The common indentation of these lines
+// is removed, any further indentation is part of the markdown.
+func parseNotes(s string) (diffNotes, error) {
+	type entry struct {
+		ref  string
+		body []string // first line and continuation lines, still indented
+	}
+	var entries []entry
+	for i, line := range strings.Split(s, "\n") {
+		if m := noteRefRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			n, _ := strconv.Atoi(m[1][1:])
+			if n < 1 {
+				return nil, fmt.Errorf("notes: line %d: invalid line reference %s", i+1, m[1])
+			}
+			entries = append(entries, entry{m[1], []string{m[2]}})
+			continue
+		}
+		if len(entries) == 0 {
+			if strings.TrimSpace(line) != "" {
+				return nil, fmt.Errorf("notes: line %d: expected a line reference like +12 or -7", i+1)
+			}
+			continue
+		}
+		e := &entries[len(entries)-1]
+		e.body = append(e.body, line)
+	}
+
+	notes := make(diffNotes)
+	for _, e := range entries {
+		body := append(e.body[:1], dedent(e.body[1:])...)
+		var buf bytes.Buffer
+		if err := goldmark.Convert([]byte(strings.Join(body, "\n")), &buf); err != nil {
+			return nil, fmt.Errorf("notes: rendering note %s: %v", e.ref, err)
+		}
+		notes[e.ref] = append(notes[e.ref], template.HTML(buf.String()))
+	}
+	return notes, nil
+}
+
+// dedent removes the common indentation of all non-blank lines from lines. Blank lines become
+// empty.
+func dedent(lines []string) []string {
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		ws := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		switch {
+		case first:
+			indent, first = ws, false
+		default:
+			for !strings.HasPrefix(ws, indent) {
+				indent = indent[:len(indent)-1]
+			}
+		}
+	}
+	ret := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			ret[i] = line[len(indent):]
+		}
+	}
+	return ret
+}
+
+// refs returns the line references of ed.
+func refs(ed highlight.Edit) []string {
+	switch ed.Op {
+	case diff.Delete:
+		return []string{fmt.Sprintf("-%d", ed.LineNoX)}
+	case diff.Insert:
+		return []string{fmt.Sprintf("+%d", ed.LineNoY)}
+	default:
+		return []string{fmt.Sprintf("-%d", ed.LineNoX), fmt.Sprintf("+%d", ed.LineNoY)}
+	}
+}
+
+// For returns the notes of ed.
+func (n diffNotes) For(ed highlight.Edit) []template.HTML {
+	if n == nil {
+		return nil
+	}
+	var ret []template.HTML
+	for _, ref := range refs(ed) {
+		ret = append(ret, n[ref]...)
+	}
+	return ret
+}
+
+// ForRow returns the notes of the lines in row.
+func (n diffNotes) ForRow(row splitRow) []template.HTML {
+	var ret []template.HTML
+	if row.Left != nil {
+		ret = append(ret, n.For(*row.Left)...)
+	}
+	if row.Right != nil && row.Right != row.Left {
+		ret = append(ret, n.For(*row.Right)...)
+	}
+	return ret
+}
+
+// check reports an error if a note refers to a line that's not shown in hunks. Lines that are
+// folded away aren't shown.
+func (n diffNotes) check(hunks []highlight.Hunk) error {
+	shown := make(map[string]bool)
+	for _, h := range hunks {
+		for _, ed := range h.Edits {
+			for _, ref := range refs(ed) {
+				shown[ref] = true
+			}
+		}
+	}
+	var unknown []string
+	for ref := range n {
+		if !shown[ref] {
+			unknown = append(unknown, ref)
+		}
+	}
+	slices.Sort(unknown)
+	switch len(unknown) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("notes: unknown line reference %s", unknown[0])
+	default:
+		return fmt.Errorf("notes: unknown line references %s", strings.Join(unknown, ", "))
+	}
+}
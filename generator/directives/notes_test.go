@@ -0,0 +1,110 @@
+package directives
+
+import (
+	"html/template"
+	"testing"
+
+	"flo.znkr.io/generator/highlight"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNotes(t *testing.T) {
+	in := `
++2: This is **new**.
+-1: Removed,
+    because it's unused.
+
++2: Another note.
+`
+	got, err := parseNotes(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := diffNotes{
+		"+2": {"<p>This is <strong>new</strong>.</p>\n", "<p>Another note.</p>\n"},
+		"-1": {"<p>Removed,\nbecause it's unused.</p>\n"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseNotes_Indentation(t *testing.T) {
+	in := `
++1: Steps:
+    1. First
+       - detail
+    2. Second
+`
+	got, err := parseNotes(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := diffNotes{
+		"+1": {"<p>Steps:</p>\n<ol>\n<li>First\n<ul>\n<li>detail</li>\n</ul>\n</li>\n<li>Second</li>\n</ol>\n"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDedent(t *testing.T) {
+	tests := []struct {
+		in, want []string
+	}{
+		{nil, []string{}},
+		{[]string{"  a", "    b", "", "  c"}, []string{"a", "  b", "", "c"}},
+		{[]string{"\t\ta", "\t b", "   "}, []string{"\ta", " b", ""}},
+		{[]string{"a", "  b"}, []string{"a", "  b"}},
+	}
+	for _, tt := range tests {
+		if got := dedent(tt.in); !cmp.Equal(got, tt.want) {
+			t.Errorf("dedent(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseNotes_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"missing_ref", "no reference\n", "notes: line 1: expected a line reference like +12 or -7"},
+		{"zero", "+0: zero\n", "notes: line 1: invalid line reference +0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseNotes(tt.in)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffNotes(t *testing.T) {
+	hunks, err := highlight.DiffHunks("a\nb\nc\nd\ne\nf\n", "a\nB\nc\nd\ne\nf\n", 1, highlight.Lang("text"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	notes := diffNotes{"-2": {"old"}, "+2": {"new"}, "+3": {"context"}, "-3": {"context x"}}
+	if err := notes.check(hunks); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var got []template.HTML
+	for _, ed := range hunks[0].Edits {
+		got = append(got, notes.For(ed)...)
+	}
+	want := []template.HTML{"old", "new", "context x", "context"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	// Line 6 is folded, line 7 doesn't exist.
+	notes = diffNotes{"+6": {"folded"}, "-7": {"unknown"}}
+	if err := notes.check(hunks); err == nil || err.Error() != "notes: unknown line references +6, -7" {
+		t.Errorf("got error %v, want unknown line references", err)
+	}
+}
@@ -0,0 +1,400 @@
+package directives
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"flo.znkr.io/generator/site"
+)
+
+// defaultOutputTimeout is the time a command of include-output may run if there's no explicit
+// timeout attribute.
+const defaultOutputTimeout = 2 * time.Minute
+
+// commandOutput is the recorded output of a command.
+type commandOutput struct {
+	Segments []outputSegment
+	ExitCode int
+}
+
+// outputSegment is a consecutive part of the output written to either stdout or stderr.
+type outputSegment struct {
+	Stderr bool
+	Text   string
+}
+
+// outputCache caches command outputs by a hash of the command and all its inputs. Outputs are kept
+// in memory and in the user's cache directory, so that they survive restarts.
+type outputCache struct {
+	mu    sync.Mutex
+	mem   map[string]*commandOutput
+	prune sync.Once
+}
+
+// outputCacheVersion is the version of the persistent cache, it must be changed whenever the cache
+// key or the format of the entries changes. Entries of other versions are removed.
+const outputCacheVersion = "v2"
+
+// outputCacheMaxAge is the time after which unused entries are removed from the persistent cache.
+const outputCacheMaxAge = 30 * 24 * time.Hour
+
+var commandOutputs = &outputCache{mem: make(map[string]*commandOutput)}
+
+var outputSchema = &Schema{
+	Attrs: []Attr{
+		{Name: "dir"},
+		{Name: "run"},
+		{Name: "test"},
+		{Name: "cmd"},
+		{Name: "timeout", Type: Duration},
+		{Name: "expect-failure", Type: Bool},
+		{Name: "display"},
+	},
+	Rules: []Rule{OneOf([]string{"run"}, []string{"test"}, []string{"cmd"})},
+}
+
+func (r *Renderer) includeOutput(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	var args []string
+	if pkg, ok := dir.Attrs["run"]; ok {
+		args = []string{"go", "run", cmp.Or(pkg, ".")}
+	}
+	if re, ok := dir.Attrs["test"]; ok {
+		args = []string{"go", "test", "-run", re, "."}
+	}
+	if name, ok := dir.Attrs["cmd"]; ok {
+		args, ok = r.commands[name]
+		if !ok || len(args) == 0 {
+			return fmt.Errorf("include-output: %v", r.unknownCommand(name))
+		}
+	}
+
+	timeout := defaultOutputTimeout
+	if v, ok := dir.Attrs["timeout"]; ok {
+		var err error
+		timeout, err = time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("include-output: invalid timeout attribute: %v", err)
+		}
+	}
+	expectFailure, err := dir.BoolAttr("expect-failure", false)
+	if err != nil {
+		return fmt.Errorf("include-output: %v", err)
+	}
+
+	workdir, err := r.resolver.Resolve(doc, cmp.Or(dir.Attrs["dir"], "."))
+	if err != nil {
+		return fmt.Errorf("include-output: %v", err)
+	}
+	out, err := commandOutputs.run(workdir, args, timeout, r.unisolated)
+	if err != nil {
+		return fmt.Errorf("include-output: %v", err)
+	}
+	switch {
+	case out.ExitCode != 0 && !expectFailure:
+		return fmt.Errorf("include-output: %s failed with exit code %d:\n%s", strings.Join(args, " "), out.ExitCode, out.text())
+	case out.ExitCode == 0 && expectFailure:
+		return fmt.Errorf("include-output: %s succeeded, but expected failure", strings.Join(args, " "))
+	}
+
+	err = r.output.Execute(buf, struct {
+		Command  string
+		Segments []outputSegment
+		ExitCode int
+	}{
+		Command:  cmp.Or(dir.Attrs["display"], strings.Join(args, " ")),
+		Segments: out.Segments,
+		ExitCode: out.ExitCode,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering include-output: %v", err)
+	}
+	return nil
+}
+
+// unknownCommand returns the error for the unknown command name.
+func (r *Renderer) unknownCommand(name string) error {
+	var names []string
+	for n := range r.commands {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	if sug := suggest(name, names); sug != "" {
+		return fmt.Errorf("unknown command %q, did you mean %q?", name, sug)
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func (out *commandOutput) text() string {
+	var sb strings.Builder
+	for _, seg := range out.Segments {
+		sb.WriteString(seg.Text)
+	}
+	return sb.String()
+}
+
+// run runs the command args in workdir or returns the cached output if none of its inputs changed
+// since the last run, see [outputKey]. See [runSandboxed] for unisolated.
+func (c *outputCache) run(workdir string, args []string, timeout time.Duration, unisolated bool) (*commandOutput, error) {
+	key, err := outputKey(workdir, args)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	out, ok := c.mem[key]
+	c.mu.Unlock()
+	if ok {
+		return out, nil
+	}
+
+	cacheFile := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		root := filepath.Join(dir, "flo.znkr.io", "include-output")
+		c.prune.Do(func() { pruneOutputCache(root, time.Now()) })
+		cacheFile = filepath.Join(root, outputCacheVersion, key+".json")
+		if b, err := os.ReadFile(cacheFile); err == nil {
+			out = &commandOutput{}
+			if err := json.Unmarshal(b, out); err == nil {
+				now := time.Now()
+				os.Chtimes(cacheFile, now, now) // keep used entries from being pruned
+				c.mu.Lock()
+				c.mem[key] = out
+				c.mu.Unlock()
+				return out, nil
+			}
+		}
+	}
+
+	out, err = runSandboxed(workdir, args, timeout, unisolated)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.mem[key] = out
+	c.mu.Unlock()
+	if cacheFile != "" {
+		if b, err := json.Marshal(out); err == nil {
+			if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err == nil {
+				os.WriteFile(cacheFile, b, 0644)
+			}
+		}
+	}
+	return out, nil
+}
+
+// pruneOutputCache removes all entries from the persistent cache in root that belong to another
+// version of the cache or that haven't been used since outputCacheMaxAge. Errors are ignored, the
+// cache is pruned again next time.
+func pruneOutputCache(root string, now time.Time) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.Name() != outputCacheVersion {
+			os.RemoveAll(filepath.Join(root, e.Name()))
+		}
+	}
+	dir := filepath.Join(root, outputCacheVersion)
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if fi, err := e.Info(); err == nil && now.Sub(fi.ModTime()) > outputCacheMaxAge {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// outputKey computes the cache key for running args in workdir. The key covers the command, the
+// environment and Go version it runs with, the contents of all files in workdir except for
+// markdown sources, and the go.mod and go.sum files of the module workdir belongs to.
+func outputKey(workdir string, args []string) (string, error) {
+	h := sha256.New()
+	for _, arg := range args {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+	for _, kv := range sandboxEnv() {
+		fmt.Fprintf(h, "env %q\n", kv)
+	}
+	fmt.Fprintf(h, "go %q\n", goVersion())
+	if root, ok := moduleRoot(workdir); ok {
+		for _, name := range []string{"go.mod", "go.sum"} {
+			b, err := os.ReadFile(filepath.Join(root, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return "", fmt.Errorf("hashing inputs: %v", err)
+			}
+			fmt.Fprintf(h, "module %q %x\n", name, sha256.Sum256(b))
+		}
+	}
+	err := filepath.WalkDir(workdir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || filepath.Ext(path) == ".md" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(workdir, path)
+		sum := sha256.Sum256(b)
+		fmt.Fprintf(h, "file %q %x\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("hashing inputs: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// moduleRoot returns the directory of the Go module dir belongs to.
+func moduleRoot(dir string) (string, bool) {
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// errIsolationUnavailable is returned when a command can't be isolated from the network.
+var errIsolationUnavailable = errors.New("network isolation unavailable")
+
+// isolate starts a command isolated from the network, it's replaced in tests.
+var isolate = startIsolated
+
+// runSandboxed runs args in workdir with a temporary GOCACHE and HOME, without network access, and
+// with a timeout. If the command can't be isolated from the network, runSandboxed fails unless
+// unisolated is true.
+func runSandboxed(workdir string, args []string, timeout time.Duration, unisolated bool) (*commandOutput, error) {
+	tmp, err := os.MkdirTemp("", "include-output-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	env := append(sandboxEnv(),
+		"HOME="+tmp,
+		"TMPDIR="+tmp,
+		"GOCACHE="+filepath.Join(tmp, "gocache"),
+	)
+
+	rec := &outputRecorder{}
+	command := func() *exec.Cmd {
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = workdir
+		cmd.Env = env
+		cmd.WaitDelay = time.Second
+		cmd.Stdout = rec.writer(false)
+		cmd.Stderr = rec.writer(true)
+		return cmd
+	}
+
+	cmd := command()
+	err = isolate(cmd)
+	if errors.Is(err, errIsolationUnavailable) {
+		if !unisolated {
+			return nil, fmt.Errorf("running %s: %w, running commands without it isn't allowed", strings.Join(args, " "), err)
+		}
+		log.Printf("include-output: %v, running %s without it", err, strings.Join(args, " "))
+		cmd = command()
+		err = cmd.Start()
+	}
+	if err == nil {
+		err = cmd.Wait()
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%s timed out after %v", strings.Join(args, " "), timeout)
+	}
+	out := &commandOutput{Segments: rec.segments}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		out.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("running %s: %v", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// sandboxEnv returns the environment of sandboxed commands, except for the temporary directories.
+// Modules can be used from the module cache of the user, but not downloaded (GOPROXY=off).
+func sandboxEnv() []string {
+	return []string{
+		"PATH=" + os.Getenv("PATH"),
+		"GOMODCACHE=" + goModCache(),
+		"GOPROXY=off",
+		"GOFLAGS=-mod=readonly",
+		"GOTOOLCHAIN=local",
+		"GOTELEMETRY=off",
+	}
+}
+
+// goModCache returns the module cache of the user.
+var goModCache = sync.OnceValue(func() string { return goEnv("GOMODCACHE") })
+
+// goVersion returns the version of the local Go toolchain used by sandboxed commands.
+var goVersion = sync.OnceValue(func() string { return goEnv("GOVERSION") })
+
+// goEnv returns the value of the Go environment variable name, or an empty string if it's unknown.
+func goEnv(name string) string {
+	cmd := exec.Command("go", "env", name)
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	if b, err := cmd.Output(); err == nil {
+		return strings.TrimSpace(string(b))
+	}
+	return ""
+}
+
+// outputRecorder records the interleaved output of stdout and stderr.
+type outputRecorder struct {
+	mu       sync.Mutex
+	segments []outputSegment
+}
+
+type recorderFunc func(p []byte) (int, error)
+
+func (f recorderFunc) Write(p []byte) (int, error) { return f(p) }
+
+func (r *outputRecorder) writer(stderr bool) io.Writer {
+	return recorderFunc(func(p []byte) (int, error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if n := len(r.segments); n > 0 && r.segments[n-1].Stderr == stderr {
+			r.segments[n-1].Text += string(p)
+		} else {
+			r.segments = append(r.segments, outputSegment{Stderr: stderr, Text: string(p)})
+		}
+		return len(p), nil
+	})
+}
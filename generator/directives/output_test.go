@@ -0,0 +1,279 @@
+package directives
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flo.znkr.io/generator/site"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIncludeOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := NewResolver(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates := template.Must(template.New("fragments/include_output").Parse(
+		"{{.Command}}: {{range .Segments}}{{.Text}}{{end}}({{.ExitCode}})"))
+	r := NewRenderer(templates, Options{
+		Resolver: res,
+		Commands: map[string][]string{
+			"hello": {"sh", "-c", "echo hello world"},
+			"fail":  {"sh", "-c", "echo broken; exit 2"},
+		},
+		Unisolated: true,
+	})
+	doc := &site.Doc{Source: filepath.Join(dir, "index.md")}
+
+	tests := []struct {
+		name    string
+		attrs   map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "cmd",
+			attrs: map[string]string{"cmd": "hello"},
+			want:  "sh -c echo hello world: hello world\n(0)",
+		},
+		{
+			name:  "display",
+			attrs: map[string]string{"cmd": "hello", "display": "./hello"},
+			want:  "./hello: hello world\n(0)",
+		},
+		{
+			name:    "unknown_cmd",
+			attrs:   map[string]string{"cmd": "helo"},
+			wantErr: `include-output: unknown command "helo", did you mean "hello"?`,
+		},
+		{
+			name:    "failure",
+			attrs:   map[string]string{"cmd": "fail"},
+			wantErr: "include-output: sh -c echo broken; exit 2 failed with exit code 2:\nbroken\n",
+		},
+		{
+			name:  "expect_failure",
+			attrs: map[string]string{"cmd": "fail", "expect-failure": "true"},
+			want:  "sh -c echo broken; exit 2: broken\n(2)",
+		},
+		{
+			name:    "unexpected_success",
+			attrs:   map[string]string{"cmd": "hello", "expect-failure": "true"},
+			wantErr: "include-output: sh -c echo hello world succeeded, but expected failure",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := r.Render(&buf, doc, &Directive{Name: "include-output", Attrs: tt.attrs})
+			gotErr := ""
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if diff := cmp.Diff(tt.wantErr, gotErr); diff != "" {
+				t.Fatalf("Render() error mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Errorf("Render() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputKey(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := func(args ...string) string {
+		t.Helper()
+		k, err := outputKey(dir, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	write("go.mod", "module example\n")
+	write("main.go", "package main\n")
+	k1 := key("go", "run", ".")
+	if k := key("go", "run", "."); k != k1 {
+		t.Errorf("key not stable: %s != %s", k, k1)
+	}
+	if k := key("go", "test", "-run", "."); k == k1 {
+		t.Errorf("key doesn't depend on command")
+	}
+	write("pkg/pkg.go", "package pkg\n")
+	k2 := key("go", "run", ".")
+	if k2 == k1 {
+		t.Errorf("key doesn't depend on new files")
+	}
+	write("pkg/pkg.go", "package pkg // changed\n")
+	k3 := key("go", "run", ".")
+	if k3 == k2 {
+		t.Errorf("key doesn't depend on file contents")
+	}
+	write("index.md", "# Changed prose\n")
+	if k := key("go", "run", "."); k != k3 {
+		t.Errorf("key depends on markdown sources")
+	}
+
+	// The module files are inputs even if they are outside of the working directory.
+	pkgKey := func() string {
+		t.Helper()
+		k, err := outputKey(filepath.Join(dir, "pkg"), []string{"go", "test", "."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	k4 := pkgKey()
+	write("go.sum", "example.com/m v1.0.0 h1:abc=\n")
+	if k := pkgKey(); k == k4 {
+		t.Errorf("key doesn't depend on go.sum of the module")
+	}
+}
+
+func TestPruneOutputCache(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	write := func(name string, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("old.json", now)
+	write("v1/old.json", now)
+	write(outputCacheVersion+"/used.json", now.Add(-time.Hour))
+	write(outputCacheVersion+"/unused.json", now.Add(-outputCacheMaxAge-time.Hour))
+
+	pruneOutputCache(root, now)
+
+	var got []string
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	want := []string{outputCacheVersion + "/used.json"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("remaining entries mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestRunSandboxed(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+
+	t.Run("output", func(t *testing.T) {
+		out, err := runSandboxed(dir, []string{"sh", "-c", "echo out; echo err >&2; exit 3"}, time.Minute, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &commandOutput{
+			Segments: []outputSegment{
+				{Stderr: false, Text: "out\n"},
+				{Stderr: true, Text: "err\n"},
+			},
+			ExitCode: 3,
+		}
+		if diff := cmp.Diff(want, out); diff != "" {
+			t.Errorf("runSandboxed() mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := runSandboxed(dir, []string{"sleep", "10"}, 100*time.Millisecond, true)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+			t.Errorf("runSandboxed() = %v, want timeout", err)
+		}
+	})
+
+	t.Run("isolated", func(t *testing.T) {
+		if _, err := os.Stat("/proc/net/dev"); err != nil {
+			t.Skip("/proc/net/dev not available")
+		}
+		// In a new network namespace, the loopback device is the only network device.
+		out, err := runSandboxed(dir, []string{"sh", "-c", "tail -n +3 /proc/net/dev | cut -d: -f1"}, time.Minute, false)
+		if errors.Is(err, errIsolationUnavailable) {
+			t.Skip(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Fields(out.text()); !cmp.Equal(got, []string{"lo"}) {
+			t.Errorf("network devices = %q, want only lo", got)
+		}
+	})
+
+	t.Run("isolation_unavailable", func(t *testing.T) {
+		defer func(f func(*exec.Cmd) error) { isolate = f }(isolate)
+		isolate = func(*exec.Cmd) error { return fmt.Errorf("%w: test", errIsolationUnavailable) }
+
+		if _, err := runSandboxed(dir, []string{"true"}, time.Minute, false); err == nil {
+			t.Errorf("runSandboxed() without isolation succeeded, want error")
+		}
+		out, err := runSandboxed(dir, []string{"echo", "unisolated"}, time.Minute, true)
+		if err != nil {
+			t.Fatalf("runSandboxed() with unisolated: %v", err)
+		}
+		if got, want := out.text(), "unisolated\n"; got != want {
+			t.Errorf("runSandboxed() with unisolated = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestOutputRecorder(t *testing.T) {
+	rec := &outputRecorder{}
+	stdout, stderr := rec.writer(false), rec.writer(true)
+	stdout.Write([]byte("a"))
+	stdout.Write([]byte("b\n"))
+	stderr.Write([]byte("error\n"))
+	stdout.Write([]byte("c\n"))
+
+	want := []outputSegment{
+		{Stderr: false, Text: "ab\n"},
+		{Stderr: true, Text: "error\n"},
+		{Stderr: false, Text: "c\n"},
+	}
+	if diff := cmp.Diff(want, rec.segments); diff != "" {
+		t.Errorf("segments mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,192 @@
+package directives
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"flo.znkr.io/generator/site"
+)
+
+// redactPlaceholder replaces redacted text.
+const redactPlaceholder = "REDACTED"
+
+// Redactor replaces secrets in included files with a placeholder. A nil redactor doesn't redact
+// anything.
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// Redact returns src with all matches of the patterns of red replaced by a placeholder. If a
+// pattern has capture groups, only the text matched by the groups is replaced. Line breaks in
+// replaced text are kept, so that line numbers don't change.
+func (red *Redactor) Redact(src []byte) []byte {
+	if red == nil {
+		return src
+	}
+	for _, re := range red.patterns {
+		src = redact(re, src)
+	}
+	return src
+}
+
+// Join returns a redactor that redacts everything redacted by red or other.
+func (red *Redactor) Join(other *Redactor) *Redactor {
+	switch {
+	case red == nil:
+		return other
+	case other == nil:
+		return red
+	}
+	return &Redactor{slices.Concat(red.patterns, other.patterns)}
+}
+
+func redact(re *regexp.Regexp, src []byte) []byte {
+	var out []byte
+	last := 0
+	for _, m := range re.FindAllSubmatchIndex(src, -1) {
+		spans := m[:2]
+		if len(m) > 2 {
+			spans = m[2:]
+		}
+		for i := 0; i < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			if start < last || start == end {
+				continue // unmatched, empty, or nested group
+			}
+			out = append(out, src[last:start]...)
+			out = append(out, redactPlaceholder...)
+			out = append(out, bytes.Repeat([]byte("\n"), bytes.Count(src[start:end], []byte("\n")))...)
+			last = end
+		}
+	}
+	if out == nil {
+		return src
+	}
+	return append(out, src[last:]...)
+}
+
+// redactAttrs are the attributes of directives that support redaction.
+var redactAttrs = []Attr{
+	{Name: "redact"},
+	{Name: "redact-rules"},
+}
+
+// redactor returns the redactor for the redact and redact-rules attributes of dir, or nil if dir
+// doesn't redact anything.
+func (r *Renderer) redactor(dir *Directive) (*Redactor, error) {
+	var patterns []*regexp.Regexp
+	if v, ok := dir.Attrs["redact"]; ok {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact attribute: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	if v, ok := dir.Attrs["redact-rules"]; ok {
+		for name := range strings.SplitSeq(v, ",") {
+			name = strings.TrimSpace(name)
+			re, ok := r.redactRules[name]
+			if !ok {
+				return nil, r.unknownRedactRule(name)
+			}
+			patterns = append(patterns, re)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return &Redactor{patterns}, nil
+}
+
+// unknownRedactRule returns the error for the unknown redaction rule name.
+func (r *Renderer) unknownRedactRule(name string) error {
+	var names []string
+	for n := range r.redactRules {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	if sug := suggest(name, names); sug != "" {
+		return fmt.Errorf("unknown redaction rule %q, did you mean %q?", name, sug)
+	}
+	return fmt.Errorf("unknown redaction rule %q", name)
+}
+
+// errNotServed is the error for redacting a file that the site doesn't serve.
+func errNotServed(name string) error {
+	return fmt.Errorf("%s: redaction is only supported for files served by the site, not for git sources or files outside of the site tree", name)
+}
+
+// redactedURL returns the link to the copy of the file name, referenced by a directive in doc, served
+// by the site. Redacted directives link to it instead of the file in the repository, because the
+// site serves the copy redacted too.
+func (r *Renderer) redactedURL(doc *site.Doc, name string) (string, error) {
+	p, err := r.resolver.Resolve(doc, name)
+	if err != nil {
+		return "", err
+	}
+	sp, ok := r.resolver.sitePath(p)
+	if !ok {
+		return "", errNotServed(name)
+	}
+	return sp, nil
+}
+
+// redactSource returns src with its data redacted by red. The redacted source links to the copy of
+// the file served by the site, which is redacted too, instead of the file in the repository.
+func redactSource(src *diffSource, red *Redactor) (*diffSource, error) {
+	if red == nil || src.Name == "" {
+		return src, nil
+	}
+	if src.Path == "" {
+		return nil, errNotServed(src.Name)
+	}
+	return &diffSource{
+		Name: src.Name,
+		URL:  src.Path,
+		Path: src.Path,
+		Data: red.Redact(src.Data),
+	}, nil
+}
+
+// Redactions returns the resolved paths of the files redacted by dir in doc and the redactor for
+// them. The site must serve these files redacted the same way, because redacted directives link to
+// them. Only directives whose handlers implement [FileIncluder] include files. Redactions returns
+// nil if dir doesn't redact anything or is invalid, errors are reported when dir is rendered.
+func (r *Renderer) Redactions(doc *site.Doc, dir *Directive) ([]string, *Redactor) {
+	h, ok := r.handlers[dir.Name].(FileIncluder)
+	if !ok {
+		return nil, nil
+	}
+	red, err := r.redactor(dir)
+	if err != nil || red == nil {
+		return nil, nil
+	}
+
+	var files []string
+	for _, name := range h.IncludedFiles(dir) {
+		if _, _, ok := gitSource(name); ok || name == "" || name == "/dev/null" {
+			continue
+		}
+		p, err := r.resolver.Resolve(doc, name)
+		if err != nil {
+			continue
+		}
+		if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		tree, err := listTree(p)
+		if err != nil {
+			continue
+		}
+		for _, f := range tree {
+			files = append(files, filepath.Join(p, filepath.FromSlash(f)))
+		}
+	}
+	return files, red
+}
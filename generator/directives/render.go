@@ -5,162 +5,180 @@ import (
 	"cmp"
 	"fmt"
 	"html/template"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
+	"path"
+	"regexp"
 
 	"flo.znkr.io/generator/highlight"
 	"flo.znkr.io/generator/site"
 )
 
+// Renderer renders directives with the handlers of all builtin and registered directives.
 type Renderer struct {
-	snippet, diff *template.Template
+	snippet, diff, diffSplit, diffTree, diff3, steps, output *template.Template
+	handlers                                                 map[string]Handler
+	resolver                                                 *Resolver
+	redactRules                                              map[string]*regexp.Regexp
+	commands                                                 map[string][]string
+	unisolated                                               bool
 }
 
-func NewRenderer(templates *template.Template) *Renderer {
-	return &Renderer{
-		snippet: templates.Lookup("fragments/include_snippet"),
-		diff:    templates.Lookup("fragments/include_diff"),
+// Options configure a [Renderer].
+type Options struct {
+	// Resolver resolves all files accessed by directives. If it's nil, directives can't access
+	// any files.
+	Resolver *Resolver
+
+	// RedactRules are the named redaction rules directives can refer to with the redact-rules
+	// attribute.
+	RedactRules map[string]*regexp.Regexp
+
+	// Commands are the named commands include-output can run with the cmd attribute, as argument
+	// lists starting with the program.
+	Commands map[string][]string
+
+	// Unisolated allows include-output to run commands without network isolation on systems
+	// that don't support it. Otherwise, these commands fail.
+	Unisolated bool
+}
+
+// NewRenderer returns a renderer that renders directives with templates.
+func NewRenderer(templates *template.Template, opts Options) *Renderer {
+	r := &Renderer{
+		snippet:     templates.Lookup("fragments/include_snippet"),
+		diff:        templates.Lookup("fragments/include_diff"),
+		diffSplit:   templates.Lookup("fragments/include_diff_split"),
+		diffTree:    templates.Lookup("fragments/include_diff_tree"),
+		diff3:       templates.Lookup("fragments/include_diff3"),
+		steps:       templates.Lookup("fragments/include_steps"),
+		output:      templates.Lookup("fragments/include_output"),
+		resolver:    opts.Resolver,
+		redactRules: opts.RedactRules,
+		commands:    opts.Commands,
+		unisolated:  opts.Unisolated,
+	}
+	r.handlers = r.newHandlers(templates)
+	return r
+}
+
+// Render renders the directive dir found in doc into buf. The attributes of dir are validated
+// against the schema of the directive first.
+func (r *Renderer) Render(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	h, ok := r.handlers[dir.Name]
+	if !ok {
+		return r.unknownDirective(dir.Name)
+	}
+	if err := h.Schema().Validate(dir); err != nil {
+		return fmt.Errorf("%s: %v", dir.Name, err)
+	}
+	return h.Render(buf, doc, dir)
+}
+
+var snippetSchema = &Schema{
+	Attrs: append([]Attr{
+		{Name: "file", Required: true},
+		{Name: "lines"},
+		{Name: "region"},
+		{Name: "lang"},
+		{Name: "elide"},
+		{Name: "emphasize"},
+		{Name: "display"},
+	}, redactAttrs...),
+	Rules: []Rule{Exclusive("lines", "region")},
+}
+
+func snippetFiles(dir *Directive) []string {
+	return []string{dir.Attrs["file"]}
+}
+
+func (r *Renderer) includeSnippet(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	file := dir.Attrs["file"]
+	b, err := r.resolver.ReadFile(doc, file)
+	if err != nil {
+		return fmt.Errorf("include-snippet: %v", err)
 	}
+
+	var opts []highlight.Option
+	if lang, ok := dir.Attrs["lang"]; ok {
+		opts = append(opts, highlight.Lang(lang))
+	}
+	if region, ok := dir.Attrs["region"]; ok {
+		opts = append(opts, highlight.Region(region))
+	}
+
+	// Lines are selected by line number, marker lines are removed and don't count as lines.
+	var include []lineRange
+	if sel, ok := dir.Attrs["lines"]; ok {
+		include, err = parseLineRanges(sel)
+		if err != nil {
+			return fmt.Errorf("include-snippet: invalid lines attribute: %v", err)
+		}
+	}
+
+	return r.renderSnippet(buf, doc, dir, file, b, include, opts...)
 }
 
-func (r *Renderer) Render(doc *site.Doc, data []byte) ([]byte, error) {
-	dirs, err := Parse(data)
+// renderSnippet renders the lines in include (or all lines if include is empty) of src, the
+// contents of file, using the snippet template. It handles all attributes common to directives
+// that include snippets.
+//
+// Redacted snippets link to the copy of file served by the site, which is redacted too, instead of
+// the file in the repository.
+func (r *Renderer) renderSnippet(buf *bytes.Buffer, doc *site.Doc, dir *Directive, file string, src []byte, include []lineRange, opts ...highlight.Option) error {
+	red, err := r.redactor(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse directives: %v", err)
+		return fmt.Errorf("%s: %v", dir.Name, err)
+	}
+	src = red.Redact(src)
+	url := sourceURL(path.Join(doc.Path, file))
+	if red != nil {
+		if url, err = r.redactedURL(doc, file); err != nil {
+			return fmt.Errorf("%s: %v", dir.Name, err)
+		}
 	}
 
-	if len(dirs) == 0 {
-		return data, nil
+	opts = append([]highlight.Option{highlight.LangFromFilename(file), highlight.StripRegionMarkers(), highlight.Callouts()}, opts...)
+	lines, err := highlight.Highlight(string(src), opts...)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %v", dir.Name, file, err)
 	}
 
-	var buf bytes.Buffer
-	pos := 0
-	for _, dir := range dirs {
-		buf.Write(data[pos:dir.Pos])
-
-		switch dir.Name {
-		case "include-snippet":
-			file := dir.Attrs["file"]
-			if file == "" {
-				return nil, fmt.Errorf("include-snippet: missing or empty file attribute")
-			}
-			b, err := os.ReadFile(filepath.Join(filepath.Dir(doc.Source), file))
-			if err != nil {
-				return nil, fmt.Errorf("include-snippet: %v", err)
-			}
-
-			lopt := highlight.LangFromFilename(file)
-			if lang, ok := dir.Attrs["lang"]; ok {
-				lopt = highlight.Lang(lang)
-			}
-			lines, err := highlight.Highlight(string(b), lopt)
-			if err != nil {
-				return nil, fmt.Errorf("include-snippet: %v", err)
-			}
-
-			if sel, ok := dir.Attrs["lines"]; ok {
-				from, to, _ := strings.Cut(sel, "..")
-				start, end := 0, len(lines)
-				if from != "" {
-					i, err := strconv.Atoi(from)
-					if err != nil {
-						return nil, fmt.Errorf("include-snippet: invalid lines attribute: %q", sel)
-					}
-					start = max(start, i-1)
-				}
-				if to != "" {
-					i, err := strconv.Atoi(to)
-					if err != nil {
-						return nil, fmt.Errorf("include-snippet: invalid lines attribute: %q", sel)
-					}
-					end = min(i, end)
-				}
-				lines = lines[start:end]
-			}
-
-			display := cmp.Or(dir.Attrs["display"], file)
-			err = r.snippet.Execute(&buf, struct {
-				File     string
-				FilePath string
-				Lines    []highlight.Line
-			}{
-				File:     display,
-				FilePath: filepath.Join(doc.Path, file),
-				Lines:    lines,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("rendering include-snipped: %v", err)
-			}
-
-		case "include-diff":
-			var display, path string
-			var diff []highlight.Edit
-			switch {
-			case dir.Attrs["diff"] != "" && !dir.HasAttr("a") && !dir.HasAttr("b"):
-				var lopt highlight.Option
-				if lang, ok := dir.Attrs["lang"]; ok {
-					lopt = highlight.Lang(lang)
-				}
-				raw, err := os.ReadFile(filepath.Join(filepath.Dir(doc.Source), dir.Attrs["diff"]))
-				if err != nil {
-					return nil, fmt.Errorf("include-snippet: %v", err)
-				}
-				diff, err = highlight.ParseDiff(string(raw), lopt)
-				if err != nil {
-					return nil, fmt.Errorf("include-diff: %v", err)
-				}
-				display = cmp.Or(dir.Attrs["display"], dir.Attrs["diff"])
-				path = filepath.Join(doc.Path, dir.Attrs["diff"])
-
-			case dir.Attrs["a"] != "" && dir.Attrs["b"] != "" && !dir.HasAttr("diff"):
-				var a, b []byte
-				var lopt highlight.Option
-				if lang, ok := dir.Attrs["lang"]; ok {
-					lopt = highlight.Lang(lang)
-				}
-				for name, dst := range map[string]*[]byte{dir.Attrs["a"]: &a, dir.Attrs["b"]: &b} {
-					if name == "/dev/null" {
-						continue
-					}
-					var err error
-					*dst, err = os.ReadFile(filepath.Join(filepath.Dir(doc.Source), name))
-					if err != nil {
-						return nil, fmt.Errorf("include-snippet: %v", err)
-					}
-					if lopt == nil {
-						lopt = highlight.LangFromFilename(name)
-					}
-					diff, err = highlight.Diff(string(a), string(b), lopt)
-					if err != nil {
-						return nil, fmt.Errorf("include-diff: %v", err)
-					}
-				}
-				display = cmp.Or(dir.Attrs["display"], dir.Attrs["b"])
-				path = filepath.Join(doc.Path, dir.Attrs["b"])
-			default:
-				return nil, fmt.Errorf("include-diff: either diff or a and b must be specified")
-			}
-
-			err = r.diff.Execute(&buf, struct {
-				File     string
-				FilePath string
-				Diff     []highlight.Edit
-			}{
-				File:     display,
-				FilePath: path,
-				Diff:     diff,
-			})
-			if err != nil {
-				return nil, fmt.Errorf("rendering include-diff: %v", err)
-			}
-
-		default:
-			return nil, fmt.Errorf("unknown directive: %s", dir.Name)
+	var exclude []lineRange
+	if sel, ok := dir.Attrs["elide"]; ok {
+		exclude, err = parseLineRanges(sel)
+		if err != nil {
+			return fmt.Errorf("%s: invalid elide attribute: %v", dir.Name, err)
+		}
+	}
+	var emphasize []lineRange
+	if sel, ok := dir.Attrs["emphasize"]; ok {
+		emphasize, err = parseLineRanges(sel)
+		if err != nil {
+			return fmt.Errorf("%s: invalid emphasize attribute: %v", dir.Name, err)
 		}
-		pos = dir.End
 	}
-	buf.Write(data[pos:])
-	return buf.Bytes(), nil
+
+	selected := selectLines(lines, include, exclude)
+	hasCallouts := false
+	for i := range selected {
+		l := &selected[i]
+		l.Emphasized = !l.Elision && inRanges(l.LineNo, emphasize)
+		hasCallouts = hasCallouts || len(l.Callouts) > 0
+	}
+
+	display := cmp.Or(dir.Attrs["display"], file)
+	err = r.snippet.Execute(buf, struct {
+		File        string
+		URL         string
+		Lines       []snippetLine
+		HasCallouts bool
+	}{
+		File:        display,
+		URL:         url,
+		Lines:       selected,
+		HasCallouts: hasCallouts,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering %s: %v", dir.Name, err)
+	}
+	return nil
 }
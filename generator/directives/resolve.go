@@ -0,0 +1,104 @@
+package directives
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"flo.znkr.io/generator/site"
+)
+
+// Resolver resolves the paths of files accessed by directives. Every path must be inside of the
+// site tree or one of the explicitly allowed directories outside of it. Symlinks are resolved
+// before the path is checked, a symlink can't be used to escape the site tree.
+type Resolver struct {
+	root  string
+	allow []string
+}
+
+// NewResolver returns a resolver confined to the directory root and the directories in allow.
+func NewResolver(root string, allow ...string) (*Resolver, error) {
+	r := &Resolver{}
+	var err error
+	if r.root, err = realPath(root); err != nil {
+		return nil, fmt.Errorf("resolving site root: %v", err)
+	}
+	for _, dir := range allow {
+		p, err := realPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving allowed directory: %v", err)
+		}
+		r.allow = append(r.allow, p)
+	}
+	return r, nil
+}
+
+// Resolve resolves the path name referenced by a directive in doc. The name is relative to the
+// source of doc. Resolve returns the path with all symlinks resolved, or an error if the file
+// doesn't exist or is outside of the site tree and all allowed directories.
+func (r *Resolver) Resolve(doc *site.Doc, name string) (string, error) {
+	p, err := realPath(filepath.Join(filepath.Dir(doc.Source), name))
+	if err != nil {
+		return "", err
+	}
+	if err := r.check(name, p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// ReadFile reads the file name referenced by a directive in doc, see [Resolver.Resolve].
+func (r *Resolver) ReadFile(doc *site.Doc, name string) ([]byte, error) {
+	p, err := r.Resolve(doc, name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+// sitePath returns the path of the copy of the resolved file p served by the site. It returns false
+// if the site doesn't serve p, because it's outside of the site tree, hidden, or ignored.
+func (r *Resolver) sitePath(p string) (string, bool) {
+	if r == nil || !within(r.root, p) || strings.HasPrefix(filepath.Base(p), ".") {
+		return "", false
+	}
+	for d := filepath.Dir(p); within(r.root, d); d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".ignore")); err == nil {
+			return "", false
+		}
+		if d == r.root {
+			break
+		}
+	}
+	rel, _ := filepath.Rel(r.root, p)
+	return "/" + filepath.ToSlash(rel), true
+}
+
+// check reports an error if the path p, resolved from name, is not allowed.
+func (r *Resolver) check(name, p string) error {
+	if r == nil {
+		return fmt.Errorf("%s: file access is not supported", name)
+	}
+	for _, dir := range append([]string{r.root}, r.allow...) {
+		if within(dir, p) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s resolves to %s, which is outside of the site tree", name, p)
+}
+
+// within reports whether the path p is dir or inside of dir.
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath returns the absolute path of name with all symlinks resolved.
+func realPath(name string) (string, error) {
+	p, err := filepath.Abs(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(p)
+}
@@ -0,0 +1,83 @@
+package directives
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flo.znkr.io/generator/site"
+)
+
+func TestResolver(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink := func(target, name string) {
+		t.Helper()
+		if err := os.Symlink(target, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	write("site/post/index.md")
+	write("site/post/a.go")
+	write("site/shared/b.go")
+	write("examples/c.go")
+	write("secret/key")
+	symlink("../../secret/key", "site/post/key")
+	symlink("../../examples", "site/post/examples")
+	symlink("../site/shared/b.go", "examples/b.go")
+
+	res, err := NewResolver(filepath.Join(dir, "site"), filepath.Join(dir, "examples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := &site.Doc{Source: filepath.Join(dir, "site/post/index.md")}
+
+	tests := []struct {
+		name string
+		want string // resolved path relative to dir, empty if an error is expected
+	}{
+		{"a.go", "site/post/a.go"},
+		{".", "site/post"},
+		{"../shared/b.go", "site/shared/b.go"},
+		{"../../examples/c.go", "examples/c.go"},
+		{"examples/c.go", "examples/c.go"},
+		{"examples/b.go", "site/shared/b.go"},
+		{"../../secret/key", ""},
+		{"key", ""},
+		{"missing.go", ""},
+	}
+	for _, tt := range tests {
+		got, err := res.Resolve(doc, tt.name)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("Resolve(%q) = %q, want error", tt.name, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("Resolve(%q): unexpected error: %v", tt.name, err)
+		case tt.want != "":
+			want, _ := realPath(filepath.Join(dir, tt.want))
+			if got != want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.name, got, want)
+			}
+		}
+	}
+
+	// Without allowed directories, only the site tree is accessible.
+	res, err = NewResolver(filepath.Join(dir, "site"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../../examples/c.go", "examples/c.go"} {
+		if got, err := res.Resolve(doc, name); err == nil {
+			t.Errorf("Resolve(%q) = %q, want error", name, got)
+		}
+	}
+}
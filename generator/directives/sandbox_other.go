@@ -0,0 +1,14 @@
+//go:build !linux
+
+package directives
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// startIsolated always fails on platforms without network namespaces.
+func startIsolated(cmd *exec.Cmd) error {
+	return fmt.Errorf("%w: not supported on %s", errIsolationUnavailable, runtime.GOOS)
+}
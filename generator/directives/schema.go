@@ -0,0 +1,193 @@
+package directives
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema declares the attributes of a directive. Directives are validated against their schema
+// before they are rendered.
+type Schema struct {
+	Attrs []Attr
+
+	// Rules are additional constraints on the attributes, e.g. [Exclusive] or [OneOf].
+	Rules []Rule
+}
+
+// Attr declares an attribute of a directive.
+type Attr struct {
+	Name     string
+	Type     AttrType
+	Required bool // the attribute must be set to a non-empty value
+}
+
+// AttrType is the type of an attribute value.
+type AttrType int
+
+const (
+	String   AttrType = iota
+	Bool              // parsed by [strconv.ParseBool]
+	Int               // parsed by [strconv.Atoi]
+	Duration          // parsed by [time.ParseDuration]
+)
+
+// Rule is a constraint on the attributes of a directive.
+type Rule func(dir *Directive) error
+
+// Exclusive returns a rule that reports an error if more than one of the attributes names is set.
+func Exclusive(names ...string) Rule {
+	return func(dir *Directive) error {
+		var set []string
+		for _, name := range names {
+			if dir.HasAttr(name) {
+				set = append(set, name)
+			}
+		}
+		if len(set) > 1 {
+			return fmt.Errorf("%s are mutually exclusive", list(set, "and"))
+		}
+		return nil
+	}
+}
+
+// OneOf returns a rule that requires that the attributes of exactly one of the groups are set. No
+// attribute of any other group may be set.
+func OneOf(groups ...[]string) Rule {
+	return func(dir *Directive) error {
+		var set, complete int
+		for _, g := range groups {
+			n := 0
+			for _, name := range g {
+				if dir.HasAttr(name) {
+					n++
+				}
+			}
+			if n > 0 {
+				set++
+			}
+			if n == len(g) {
+				complete++
+			}
+		}
+		if set == 1 && complete == 1 {
+			return nil
+		}
+		alts := make([]string, len(groups))
+		for i, g := range groups {
+			alts[i] = list(g, "and")
+		}
+		if len(groups) == 2 {
+			return fmt.Errorf("either %s or %s must be specified", alts[0], alts[1])
+		}
+		return fmt.Errorf("exactly one of %s must be specified", list(alts, "or"))
+	}
+}
+
+// list joins the items into a list: "a", "a and b", "a, b, and c".
+func list(items []string, conj string) string {
+	switch len(items) {
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " " + conj + " " + items[1]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + ", " + conj + " " + items[len(items)-1]
+	}
+}
+
+// Validate reports an error if the attributes of dir don't match the schema.
+func (s *Schema) Validate(dir *Directive) error {
+	var names []string
+	for _, a := range s.Attrs {
+		names = append(names, a.Name)
+	}
+	var unknown []string
+	for name := range dir.Attrs {
+		if !slices.Contains(names, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		name := slices.Min(unknown)
+		if sug := suggest(name, names); sug != "" {
+			return fmt.Errorf("unknown attribute %q, did you mean %q?", name, sug)
+		}
+		return fmt.Errorf("unknown attribute %q", name)
+	}
+
+	for _, a := range s.Attrs {
+		v, ok := dir.Attrs[a.Name]
+		if a.Required && v == "" {
+			return fmt.Errorf("missing or empty %s attribute", a.Name)
+		}
+		if !ok {
+			continue
+		}
+		var err error
+		switch a.Type {
+		case Bool:
+			_, err = strconv.ParseBool(v)
+		case Int:
+			_, err = strconv.Atoi(v)
+		case Duration:
+			_, err = time.ParseDuration(v)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s attribute: %q", a.Name, v)
+		}
+	}
+
+	for _, rule := range s.Rules {
+		if err := rule(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// suggest returns the candidate closest to name, or "" if there's no candidate close enough to be
+// a likely typo.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", 0
+	for _, c := range candidates {
+		d := editDistance(name, c)
+		if d > 2 || d >= len(c) {
+			continue
+		}
+		if best == "" || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance between a and b: The number of
+// insertions, deletions, substitutions, and transpositions of adjacent characters needed to turn a
+// into b.
+func editDistance(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	d := make([][]int, len(x)+1)
+	for i := range d {
+		d[i] = make([]int, len(y)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(x)][len(y)]
+}
@@ -0,0 +1,244 @@
+package directives
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flo.znkr.io/generator/site"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	schema := &Schema{
+		Attrs: []Attr{
+			{Name: "file", Required: true},
+			{Name: "lines"},
+			{Name: "region"},
+			{Name: "context", Type: Int},
+			{Name: "doc", Type: Bool},
+			{Name: "timeout", Type: Duration},
+			{Name: "diff"},
+			{Name: "a"},
+			{Name: "b"},
+		},
+		Rules: []Rule{
+			Exclusive("lines", "region"),
+			OneOf([]string{"diff"}, []string{"a", "b"}),
+		},
+	}
+
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  string
+	}{
+		{
+			name:  "valid",
+			attrs: map[string]string{"file": "a.go", "lines": "1..2", "context": "3", "doc": "true", "timeout": "1s", "diff": "x.diff"},
+		},
+		{
+			name:  "unknown_with_suggestion",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "lnies": "1..2"},
+			want:  `unknown attribute "lnies", did you mean "lines"?`,
+		},
+		{
+			name:  "unknown_without_suggestion",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "color": "red"},
+			want:  `unknown attribute "color"`,
+		},
+		{
+			name:  "missing_required",
+			attrs: map[string]string{"diff": "x.diff"},
+			want:  "missing or empty file attribute",
+		},
+		{
+			name:  "empty_required",
+			attrs: map[string]string{"file": "", "diff": "x.diff"},
+			want:  "missing or empty file attribute",
+		},
+		{
+			name:  "invalid_int",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "context": "many"},
+			want:  `invalid context attribute: "many"`,
+		},
+		{
+			name:  "invalid_bool",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "doc": "maybe"},
+			want:  `invalid doc attribute: "maybe"`,
+		},
+		{
+			name:  "invalid_duration",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "timeout": "soon"},
+			want:  `invalid timeout attribute: "soon"`,
+		},
+		{
+			name:  "exclusive",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "lines": "1", "region": "r"},
+			want:  "lines and region are mutually exclusive",
+		},
+		{
+			name:  "one_of_none",
+			attrs: map[string]string{"file": "a.go"},
+			want:  "either diff or a and b must be specified",
+		},
+		{
+			name:  "one_of_incomplete",
+			attrs: map[string]string{"file": "a.go", "a": "x"},
+			want:  "either diff or a and b must be specified",
+		},
+		{
+			name:  "one_of_both",
+			attrs: map[string]string{"file": "a.go", "diff": "x.diff", "a": "x", "b": "y"},
+			want:  "either diff or a and b must be specified",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(&Directive{Name: "test", Attrs: tt.attrs})
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOneOf_Message(t *testing.T) {
+	err := OneOf([]string{"run"}, []string{"test"}, []string{"cmd"})(&Directive{})
+	want := "exactly one of run, test, or cmd must be specified"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"lines", "lines", 0},
+		{"lnies", "lines", 1},
+		{"line", "lines", 1},
+		{"include-snipet", "include-snippet", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+type echoHandler struct{}
+
+func (echoHandler) Schema() *Schema {
+	return &Schema{Attrs: []Attr{{Name: "text", Required: true}}}
+}
+
+func (echoHandler) Render(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	buf.WriteString(dir.Attrs["text"])
+	return nil
+}
+
+// catHandler renders the contents of the file in the file attribute.
+type catHandler struct {
+	res *Resolver
+}
+
+func (catHandler) Schema() *Schema {
+	return &Schema{Attrs: []Attr{{Name: "file", Required: true}}}
+}
+
+func (catHandler) IncludedFiles(dir *Directive) []string {
+	return []string{dir.Attrs["file"]}
+}
+
+func (h catHandler) Render(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	b, err := h.res.ReadFile(doc, dir.Attrs["file"])
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func init() {
+	// Directives can only be registered once, tests must not register them again when they're
+	// run repeatedly.
+	Register("test-echo", func(*template.Template, *Resolver) Handler { return echoHandler{} })
+	Register("test-cat", func(_ *template.Template, res *Resolver) Handler { return catHandler{res} })
+}
+
+func TestRenderer_Register(t *testing.T) {
+	r := NewRenderer(template.New(""), Options{})
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, &site.Doc{}, &Directive{Name: "test-echo", Attrs: map[string]string{"text": "hello"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != "hello" {
+		t.Errorf("got %q, want %q", got, "hello")
+	}
+
+	tests := []struct {
+		dir  *Directive
+		want string
+	}{
+		{&Directive{Name: "test-echo", Attrs: map[string]string{"txt": "hello"}}, `test-echo: unknown attribute "txt", did you mean "text"?`},
+		{&Directive{Name: "include-snipet"}, "unknown directive: include-snipet, did you mean include-snippet?"},
+		{&Directive{Name: "frobnicate"}, "unknown directive: frobnicate"},
+	}
+	for _, tt := range tests {
+		err := r.Render(&buf, &site.Doc{}, tt.dir)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Render(%s) = %v, want %q", tt.dir.Name, err, tt.want)
+		}
+	}
+}
+
+func TestRenderer_RegisterResolver(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"site/post/index.md": "",
+		"site/post/a.txt":    "inside",
+		"secret.txt":         "outside",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := NewResolver(filepath.Join(dir, "site"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRenderer(template.New(""), Options{Resolver: res})
+	doc := &site.Doc{Source: filepath.Join(dir, "site/post/index.md")}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, doc, &Directive{Name: "test-cat", Attrs: map[string]string{"file": "a.txt"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := buf.String(); got != "inside" {
+		t.Errorf("got %q, want %q", got, "inside")
+	}
+
+	buf.Reset()
+	err = r.Render(&buf, doc, &Directive{Name: "test-cat", Attrs: map[string]string{"file": "../../secret.txt"}})
+	if err == nil || !strings.Contains(err.Error(), "outside of the site tree") {
+		t.Errorf("Render() = %v, want error for file outside of the site tree", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("Render() wrote %q for file outside of the site tree", buf.String())
+	}
+}
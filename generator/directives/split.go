@@ -0,0 +1,71 @@
+package directives
+
+import (
+	"flo.znkr.io/generator/highlight"
+	"znkr.io/diff"
+)
+
+// splitBlock is a block of rows of a side-by-side diff.
+type splitBlock struct {
+	Header  string // hunk header, if the diff is folded
+	Folded  int    // number of folded unchanged lines, Rows holds them for unfolding
+	Changed bool   // true if the block consists of deletes and inserts
+	Rows    []splitRow
+}
+
+// splitRow is a row of a side-by-side diff. Either side is nil if there's no line to show.
+type splitRow struct {
+	Left, Right *highlight.Edit
+}
+
+// splitDiff arranges the edits of hunks in aligned left and right rows. Deletes and inserts that
+// directly follow each other are paired up into the same rows.
+func splitDiff(hunks []highlight.Hunk, folded bool) []splitBlock {
+	var ret []splitBlock
+	for _, h := range hunks {
+		if len(h.Folded) > 0 {
+			rows := make([]splitRow, len(h.Folded))
+			for i := range h.Folded {
+				rows[i] = splitRow{&h.Folded[i], &h.Folded[i]}
+			}
+			ret = append(ret, splitBlock{Folded: len(h.Folded), Rows: rows})
+		}
+		if folded && len(h.Edits) > 0 {
+			ret = append(ret, splitBlock{Header: h.Header()})
+		}
+		edits := h.Edits
+		for i := 0; i < len(edits); {
+			if edits[i].Op == diff.Match {
+				j := i
+				var rows []splitRow
+				for ; j < len(edits) && edits[j].Op == diff.Match; j++ {
+					rows = append(rows, splitRow{&edits[j], &edits[j]})
+				}
+				ret = append(ret, splitBlock{Rows: rows})
+				i = j
+				continue
+			}
+
+			var dels, inss []*highlight.Edit
+			for ; i < len(edits) && edits[i].Op != diff.Match; i++ {
+				switch edits[i].Op {
+				case diff.Delete:
+					dels = append(dels, &edits[i])
+				case diff.Insert:
+					inss = append(inss, &edits[i])
+				}
+			}
+			rows := make([]splitRow, max(len(dels), len(inss)))
+			for k := range rows {
+				if k < len(dels) {
+					rows[k].Left = dels[k]
+				}
+				if k < len(inss) {
+					rows[k].Right = inss[k]
+				}
+			}
+			ret = append(ret, splitBlock{Changed: true, Rows: rows})
+		}
+	}
+	return ret
+}
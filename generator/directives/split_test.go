@@ -0,0 +1,58 @@
+package directives
+
+import (
+	"fmt"
+	"testing"
+
+	"flo.znkr.io/generator/highlight"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\n"
+	b := "a\nB\nC\nX\nd\ne\n"
+	hunks, err := highlight.DiffHunks(a, b, 1, highlight.Lang("text"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	side := func(ed *highlight.Edit, x bool) string {
+		switch {
+		case ed == nil:
+			return "_"
+		case x:
+			return fmt.Sprint(ed.LineNoX)
+		default:
+			return fmt.Sprint(ed.LineNoY)
+		}
+	}
+	var got []string
+	for _, blk := range splitDiff(hunks, true) {
+		switch {
+		case blk.Folded > 0:
+			got = append(got, fmt.Sprintf("folded %d", blk.Folded))
+			for _, row := range blk.Rows {
+				got = append(got, fmt.Sprintf("folded %s|%s", side(row.Left, true), side(row.Right, false)))
+			}
+		case blk.Header != "":
+			got = append(got, blk.Header)
+		default:
+			for _, row := range blk.Rows {
+				got = append(got, fmt.Sprintf("%v %s|%s", blk.Changed, side(row.Left, true), side(row.Right, false)))
+			}
+		}
+	}
+	want := []string{
+		"@@ -1,4 +1,5 @@",
+		"false 1|1",
+		"true 2|2",
+		"true 3|3",
+		"true _|4",
+		"false 4|5",
+		"folded 1",
+		"folded 5|6",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("splitDiff() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,127 @@
+package directives
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"flo.znkr.io/generator/site"
+)
+
+// stepEntry is an entry in the steps attribute of include-steps.
+type stepEntry struct {
+	path, title string
+}
+
+// step is the data for rendering a single step of include-steps.
+type step struct {
+	ID, PrevID, NextID string
+	No                 int
+	Title              string
+	Body               template.HTML
+}
+
+// parseSteps parses the steps attribute. Steps are separated by commas or newlines, each step is
+// a path optionally followed by a title.
+func parseSteps(s string) []stepEntry {
+	var ret []stepEntry
+	for _, entry := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		path, title, _ := strings.Cut(strings.TrimSpace(entry), " ")
+		if path == "" {
+			continue
+		}
+		ret = append(ret, stepEntry{path, strings.TrimSpace(title)})
+	}
+	return ret
+}
+
+var stepsSchema = &Schema{
+	Attrs: append([]Attr{
+		{Name: "steps", Required: true},
+		{Name: "id"},
+	}, diffSettingsAttrs...),
+}
+
+// includeSteps renders a stepper through successive versions of a file or directory. Every step
+// shows the diff to the previous step. All steps are rendered, without JavaScript they are shown
+// one after another.
+func (r *Renderer) includeSteps(buf *bytes.Buffer, doc *site.Doc, dir *Directive) error {
+	entries := parseSteps(dir.Attrs["steps"])
+	if len(entries) == 0 {
+		return fmt.Errorf("include-steps: missing or empty steps attribute")
+	}
+	ds, err := parseDiffSettings(dir)
+	if err != nil {
+		return fmt.Errorf("include-steps: %v", err)
+	}
+	dirs := r.isDir(doc, entries[0].path)
+	for _, e := range entries[1:] {
+		if r.isDir(doc, e.path) != dirs {
+			return fmt.Errorf("include-steps: steps must be either all files or all directories")
+		}
+	}
+
+	id := cmp.Or(dir.Attrs["id"], fmt.Sprintf("steps-%d", dir.Pos))
+	stepID := func(i int) string {
+		if i < 0 || i >= len(entries) {
+			return ""
+		}
+		return fmt.Sprintf("%s-%d", id, i+1)
+	}
+
+	steps := make([]step, len(entries))
+	for i, e := range entries {
+		var body bytes.Buffer
+		if dirs {
+			prev := ""
+			if i > 0 {
+				prev = entries[i-1].path
+			}
+			if err := r.renderDiffTree(&body, doc, prev, e.path, ds); err != nil {
+				return fmt.Errorf("include-steps: %v", err)
+			}
+		} else {
+			b, err := r.readDiffSource(doc, e.path)
+			if err != nil {
+				return fmt.Errorf("include-steps: %v", err)
+			}
+			a := b // the first step shows the file without changes
+			if i > 0 {
+				a, err = r.readDiffSource(doc, entries[i-1].path)
+				if err != nil {
+					return fmt.Errorf("include-steps: %v", err)
+				}
+			}
+			t, err := compareSources(a, b, ds)
+			if err != nil {
+				return fmt.Errorf("include-steps: %v", err)
+			}
+			t.Full = ds.context < 0
+			if err := r.renderDiffTable(&body, t, ds.split); err != nil {
+				return fmt.Errorf("rendering include-steps: %v", err)
+			}
+		}
+		steps[i] = step{
+			ID:     stepID(i),
+			PrevID: stepID(i - 1),
+			NextID: stepID(i + 1),
+			No:     i + 1,
+			Title:  cmp.Or(e.title, e.path),
+			Body:   template.HTML(body.String()),
+		}
+	}
+
+	err = r.steps.Execute(buf, struct {
+		ID    string
+		Steps []step
+	}{
+		ID:    id,
+		Steps: steps,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering include-steps: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package directives
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []stepEntry
+	}{
+		{"a.go, b.go", []stepEntry{{"a.go", ""}, {"b.go", ""}}},
+		{"a.go First version,b.go Second", []stepEntry{{"a.go", "First version"}, {"b.go", "Second"}}},
+		{"\n  01/a.go  Start\n  02/a.go\n", []stepEntry{{"01/a.go", "Start"}, {"02/a.go", ""}}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		got := parseSteps(tt.in)
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(stepEntry{})); diff != "" {
+			t.Errorf("parseSteps(%q) mismatch (-want +got):\n%s", tt.in, diff)
+		}
+	}
+}
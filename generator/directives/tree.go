@@ -0,0 +1,147 @@
+package directives
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"flo.znkr.io/generator/site"
+	"znkr.io/diff"
+)
+
+// treeFile is a changed file in a diff of two directory trees.
+type treeFile struct {
+	Name           string
+	Status         string // added, removed, or modified
+	Added, Deleted int
+	Binary         bool
+	Table          template.HTML
+}
+
+// renderDiffTree renders the diff of the directories aDir and bDir, relative to the source of doc.
+// Files are paired by their path relative to aDir and bDir, every changed file is rendered as a
+// separate diff table. If aDir is empty, all files in bDir are added.
+func (r *Renderer) renderDiffTree(buf *bytes.Buffer, doc *site.Doc, aDir, bDir string, ds diffSettings) error {
+	var aFiles []string
+	if aDir != "" {
+		root, err := r.resolver.Resolve(doc, aDir)
+		if err != nil {
+			return err
+		}
+		if aFiles, err = listTree(root); err != nil {
+			return err
+		}
+	}
+	root, err := r.resolver.Resolve(doc, bDir)
+	if err != nil {
+		return err
+	}
+	bFiles, err := listTree(root)
+	if err != nil {
+		return err
+	}
+	names := slices.Concat(aFiles, bFiles)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	var files []treeFile
+	var added, deleted int
+	for _, name := range names {
+		a, b := &diffSource{}, &diffSource{}
+		status := "modified"
+		switch {
+		case !slices.Contains(aFiles, name):
+			status = "added"
+		case !slices.Contains(bFiles, name):
+			status = "removed"
+		}
+		if status != "added" {
+			if a, err = r.readDiffSource(doc, path.Join(aDir, name)); err != nil {
+				return err
+			}
+		}
+		if status != "removed" {
+			if b, err = r.readDiffSource(doc, path.Join(bDir, name)); err != nil {
+				return err
+			}
+		}
+		if status == "modified" && bytes.Equal(a.Data, b.Data) {
+			continue
+		}
+
+		f := treeFile{Name: name, Status: status}
+		if bytes.IndexByte(a.Data, 0) >= 0 || bytes.IndexByte(b.Data, 0) >= 0 {
+			f.Binary = true
+			files = append(files, f)
+			continue
+		}
+		t, err := compareSources(a, b, ds)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		t.File = name
+		for _, h := range t.Hunks {
+			for _, ed := range h.Edits {
+				switch ed.Op {
+				case diff.Insert:
+					f.Added++
+				case diff.Delete:
+					f.Deleted++
+				}
+			}
+		}
+		var tbuf bytes.Buffer
+		if err := r.renderDiffTable(&tbuf, t, ds.split); err != nil {
+			return fmt.Errorf("rendering diff: %v", err)
+		}
+		f.Table = template.HTML(tbuf.String())
+		added += f.Added
+		deleted += f.Deleted
+		files = append(files, f)
+	}
+
+	err = r.diffTree.Execute(buf, struct {
+		Files          []treeFile
+		Added, Deleted int
+	}{
+		Files:   files,
+		Added:   added,
+		Deleted: deleted,
+	})
+	if err != nil {
+		return fmt.Errorf("rendering diff tree: %v", err)
+	}
+	return nil
+}
+
+// listTree returns the slash-separated paths of all regular files in the directory tree at root,
+// relative to root. Hidden files and directories are skipped.
+func listTree(root string) ([]string, error) {
+	var ret []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, filepath.ToSlash(rel))
+		return nil
+	})
+	return ret, err
+}
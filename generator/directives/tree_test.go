@@ -0,0 +1,31 @@
+package directives
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListTree(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "sub/b.go", "sub/deeper/c.txt", ".hidden", ".git/config", "sub/.cache/x"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := listTree(root)
+	if err != nil {
+		t.Fatalf("listTree: unexpected error: %v", err)
+	}
+	want := []string{"a.go", "sub/b.go", "sub/deeper/c.txt"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("listTree() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build embedsite
+
+package main
+
+import _ "embed"
+
+// embeddedSite is the packed site embedded in the binary. To embed a site, pack it into
+// generator/site.tar and build with the embedsite build tag.
+//
+//go:embed site.tar
+var embeddedSite []byte
@@ -0,0 +1,6 @@
+//go:build !embedsite
+
+package main
+
+// embeddedSite is nil, because the binary was built without the embedsite build tag.
+var embeddedSite []byte
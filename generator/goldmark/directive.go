@@ -0,0 +1,214 @@
+package goldmark
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/directives"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// DirectiveFunc renders the directive dir into buf.
+type DirectiveFunc func(buf *bytes.Buffer, dir *directives.Directive) error
+
+// Directive is a block node for a directive. Directives are blocks that start with <!--# at the
+// beginning of a line, they can span multiple lines. Escaping the opening bracket (\<!--#) turns a
+// directive into regular text.
+type Directive struct {
+	ast.BaseBlock
+
+	// Directive is the parsed directive, Pos and End are offsets in the source. It's nil if the
+	// directive is invalid.
+	Directive *directives.Directive
+
+	// Err is the error found when parsing the directive.
+	Err error
+
+	// Offset is the offset in the source errors are reported at.
+	Offset int
+
+	buf      []byte // the lines of the directive so far
+	complete bool
+}
+
+// KindDirective is the node kind of [Directive].
+var KindDirective = ast.NewNodeKind("Directive")
+
+func (n *Directive) Kind() ast.NodeKind { return KindDirective }
+
+func (n *Directive) Dump(source []byte, level int) {
+	m := map[string]string{}
+	if n.Directive != nil {
+		m["Name"] = n.Directive.Name
+	}
+	if n.Err != nil {
+		m["Err"] = n.Err.Error()
+	}
+	ast.DumpHelper(n, source, level, m, nil)
+}
+
+// directiveExtension parses directives and renders them with render. Errors are collected in diags.
+type directiveExtension struct {
+	render DirectiveFunc
+	diags  *diag.List
+}
+
+func (e *directiveExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			// Before HTML blocks, which would otherwise take directives for HTML comments.
+			util.Prioritized(&directiveParser{}, 850),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&directiveRenderer{e.render, e.diags}, 500),
+		),
+	)
+}
+
+type directiveParser struct{}
+
+var _ parser.BlockParser = (*directiveParser)(nil)
+
+func (p *directiveParser) Trigger() []byte {
+	return []byte{'<'}
+}
+
+func (p *directiveParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("<!--#")) {
+		return nil, parser.NoChildren
+	}
+	node := &Directive{}
+	seg := text.NewSegment(segment.Start+pos, segment.Stop)
+	node.Lines().Append(seg)
+	node.buf = append(node.buf, seg.Value(reader.Source())...)
+	reader.AdvanceToEOL()
+	p.parse(node, reader.Source(), false)
+	return node, parser.NoChildren
+}
+
+func (p *directiveParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*Directive)
+	if n.complete {
+		return parser.Close
+	}
+	_, segment := reader.PeekLine()
+	line := segment.Value(reader.Source())
+	n.Lines().Append(segment)
+	n.buf = append(n.buf, line...)
+	reader.AdvanceToEOL()
+	// A directive can only be complete once its closing "-->" has been read, there's no need to
+	// parse it again for lines without one.
+	if bytes.Contains(line, []byte("-->")) && p.parse(n, reader.Source(), false) {
+		return parser.Close
+	}
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *directiveParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	n := node.(*Directive)
+	if !n.complete {
+		// The directive is unterminated, parse it once more to report the error.
+		p.parse(n, reader.Source(), true)
+	}
+}
+
+// parse parses the lines of n and reports whether the directive is complete. A directive is
+// incomplete if the parser reaches the end of the lines, unless atEOF is true.
+func (p *directiveParser) parse(n *Directive, source []byte, atEOF bool) bool {
+	buf := n.buf
+	lines := n.Lines()
+
+	// offset maps an offset in buf to an offset in source.
+	offset := func(off int) int {
+		for i := range lines.Len() {
+			seg := lines.At(i)
+			if off < seg.Len() || i == lines.Len()-1 {
+				return seg.Start + off
+			}
+			off -= seg.Len()
+		}
+		return 0
+	}
+
+	dir, err := directives.ParseDirective(buf)
+	var serr *directives.SyntaxError
+	switch {
+	case errors.As(err, &serr) && serr.Pos >= len(buf) && !atEOF:
+		return false
+	case err != nil:
+		n.Err, n.Offset = fmt.Errorf("parsing directive: %s", serr.Msg), offset(serr.Pos)
+	case len(bytes.TrimSpace(buf[dir.End:])) > 0:
+		n.Err, n.Offset = fmt.Errorf("parsing directive: unexpected text after directive"), offset(dir.End)
+	default:
+		dir.Pos, dir.End = offset(dir.Pos), offset(dir.End)
+		n.Directive, n.Offset = &dir, dir.Pos
+	}
+	n.buf = nil
+	n.complete = true
+	return true
+}
+
+func (p *directiveParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *directiveParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type directiveRenderer struct {
+	render DirectiveFunc
+	diags  *diag.List
+}
+
+var _ renderer.NodeRenderer = (*directiveRenderer)(nil)
+
+func (r *directiveRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindDirective, r.renderDirective)
+}
+
+// renderDirective renders a directive. Rendering doesn't stop at the first failing directive, the
+// errors of all failing directives are collected.
+func (r *directiveRenderer) renderDirective(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+	n := node.(*Directive)
+	err := n.Err
+	if err == nil {
+		var buf bytes.Buffer
+		if r.render == nil {
+			err = fmt.Errorf("directives are not supported")
+		} else {
+			err = r.render(&buf, n.Directive)
+		}
+		if err == nil {
+			w.Write(buf.Bytes())
+			w.WriteByte('\n')
+		}
+	}
+	if err != nil {
+		line, col := lineCol(source, n.Offset)
+		r.diags.Add("", diag.Errorf("", line, col, "%v", err))
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// lineCol returns the 1-based line and column of the offset off in src.
+func lineCol(src []byte, off int) (line, col int) {
+	line = 1 + bytes.Count(src[:off], []byte("\n"))
+	bol := bytes.LastIndexByte(src[:off], '\n') + 1
+	return line, 1 + utf8.RuneCount(src[bol:off])
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/directives"
 	"flo.znkr.io/generator/goldmark/admonitions"
 	treeblood "github.com/wyatt915/goldmark-treeblood"
 	"github.com/yuin/goldmark"
@@ -17,22 +19,14 @@ import (
 	"go.abhg.dev/goldmark/toc"
 )
 
-func Render(data []byte) ([]byte, []byte, error) {
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.Footnote,
-			extension.Table,
-			admonitions.Extension,
-			treeblood.MathML(),
-		),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
-		goldmark.WithRendererOptions(
-			html.WithUnsafe(),
-			renderer.WithNodeRenderers(util.Prioritized(&customRenderer{}, 999)),
-		),
-	)
+// Render renders the markdown in data and returns the content and the table of contents.
+// Directives are rendered with renderDirective.
+//
+// Errors in directives are reported as [diag.Diagnostic] with positions relative to data. Rendering
+// doesn't stop at the first failing directive, all failing directives are reported.
+func Render(data []byte, renderDirective DirectiveFunc) ([]byte, []byte, error) {
+	var diags diag.List
+	md := newMarkdown(&directiveExtension{renderDirective, &diags})
 
 	doc := md.Parser().Parse(text.NewReader(data))
 
@@ -45,6 +39,9 @@ func Render(data []byte) ([]byte, []byte, error) {
 	if err := md.Renderer().Render(&buf, data, doc); err != nil {
 		return nil, nil, fmt.Errorf("rendering markdown: %v", err)
 	}
+	if err := diags.Err(); err != nil {
+		return nil, nil, err
+	}
 
 	var tocbuf bytes.Buffer
 	if list := toc.RenderList(tree); list != nil {
@@ -56,6 +53,41 @@ func Render(data []byte) ([]byte, []byte, error) {
 	return buf.Bytes(), tocbuf.Bytes(), nil
 }
 
+// Directives returns all valid directives in the markdown in data, without rendering them.
+func Directives(data []byte) []*directives.Directive {
+	md := newMarkdown(&directiveExtension{})
+	doc := md.Parser().Parse(text.NewReader(data))
+
+	var dirs []*directives.Directive
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n, ok := n.(*Directive); ok && entering && n.Directive != nil {
+			dirs = append(dirs, n.Directive)
+		}
+		return ast.WalkContinue, nil
+	})
+	return dirs
+}
+
+// newMarkdown returns the markdown converter for the site, directives are handled by ext.
+func newMarkdown(ext *directiveExtension) goldmark.Markdown {
+	return goldmark.New(
+		goldmark.WithExtensions(
+			extension.Footnote,
+			extension.Table,
+			admonitions.Extension,
+			treeblood.MathML(),
+			ext,
+		),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+		goldmark.WithRendererOptions(
+			html.WithUnsafe(),
+			renderer.WithNodeRenderers(util.Prioritized(&customRenderer{}, 999)),
+		),
+	)
+}
+
 type customRenderer struct{}
 
 var _ renderer.NodeRenderer = (*customRenderer)(nil)
@@ -1,9 +1,12 @@
 package goldmark
 
 import (
+	"bytes"
+	"fmt"
 	"regexp"
 	"testing"
 
+	"flo.znkr.io/generator/directives"
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -37,7 +40,7 @@ func TestRender(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _, err := Render([]byte(tt.in))
+			got, _, err := Render([]byte(tt.in), nil)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -63,7 +66,7 @@ More content.
 
 Even more content.
 `
-	_, toc, err := Render([]byte(input))
+	_, toc, err := Render([]byte(input), nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -83,3 +86,132 @@ Even more content.
 		t.Errorf("TOC mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestRender_Directives(t *testing.T) {
+	render := func(buf *bytes.Buffer, dir *directives.Directive) error {
+		if dir.Name == "fail" {
+			return fmt.Errorf("failed")
+		}
+		fmt.Fprintf(buf, "<div>%s %q @%d</div>", dir.Name, dir.Attrs["x"], dir.Pos)
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "single_line",
+			in:   "Text\n\n<!--#test x=\"1\" -->\n",
+			want: "<p>Text</p>\n<div>test \"1\" @6</div>\n",
+		},
+		{
+			name: "interrupts_paragraph",
+			in:   "Text\n<!--#test x=\"1\" -->\nMore text\n",
+			want: "<p>Text</p>\n<div>test \"1\" @5</div>\n<p>More text</p>\n",
+		},
+		{
+			name: "multi_line",
+			in:   "<!--#test\n    x=\"\"\"a\n    b\"\"\"\n-->\n",
+			want: "<div>test \"a\\nb\" @0</div>\n",
+		},
+		{
+			name: "terminator_in_string",
+			in:   "<!--#test\n    x=\"\"\"a -->\n    b\"\"\"\n-->\n",
+			want: "<div>test \"a -->\\nb\" @0</div>\n",
+		},
+		{
+			name: "list_item",
+			in:   "- item\n\n  <!--#test\n    x=\"1\" -->\n",
+			want: "<ul>\n<li>\n<p>item</p>\n<div>test \"1\" @10</div>\n</li>\n</ul>\n",
+		},
+		{
+			name: "escaped",
+			in:   "\\<!--#test x=\"1\" -->\n",
+			want: "<p>&lt;!--#test x=&quot;1&quot; --&gt;</p>\n",
+		},
+		{
+			name: "code_block",
+			in:   "```\n<!--#test x=\"1\" -->\n```\n",
+			want: "<pre><code>&lt;!--#test x=&quot;1&quot; --&gt;\n</code></pre>\n",
+		},
+		{
+			name: "comment",
+			in:   "<!-- not a directive -->\n",
+			want: "<!-- not a directive -->\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := Render([]byte(tt.in), render)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Render() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRender_DirectiveErrors(t *testing.T) {
+	render := func(buf *bytes.Buffer, dir *directives.Directive) error {
+		return fmt.Errorf("%s failed", dir.Name)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "all_errors",
+			in:   "# Title\n\n<!--#a -->\n\n  <!--#b -->\n",
+			want: ":3:1: error: a failed\n:5:3: error: b failed",
+		},
+		{
+			name: "syntax_error",
+			in:   "Text\n\n<!--#a\n    x=y -->\n",
+			want: ":4:7: error: parsing directive: unexpected 'y', expected '\"'",
+		},
+		{
+			name: "syntax_error_before_terminator",
+			in:   "<!--#a\n    x=y\n-->\nText\n",
+			want: ":2:7: error: parsing directive: unexpected 'y', expected '\"'",
+		},
+		{
+			name: "unterminated",
+			in:   "<!--#a x=\"\"\"1\n\nText\n",
+			want: ":4:1: error: parsing directive: unterminated tri-quoted string",
+		},
+		{
+			name: "trailing_text",
+			in:   "<!--#a --> text\n",
+			want: ":1:11: error: parsing directive: unexpected text after directive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Render([]byte(tt.in), render)
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			if diff := cmp.Diff(tt.want, err.Error()); diff != "" {
+				t.Errorf("error mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	in := "<!--#a x=\"1\" -->\n\n```\n<!--#b -->\n```\n\n\\<!--#c -->\n\n- item\n\n  <!--#d x=\"2\" -->\n\n<!--#e x=y -->\n"
+	var got []string
+	for _, dir := range Directives([]byte(in)) {
+		got = append(got, dir.Name+" "+dir.Attrs["x"])
+	}
+	want := []string{"a 1", "d 2"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Directives() mismatch (-want +got):\n%s", diff)
+	}
+}
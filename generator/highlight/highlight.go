@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"html"
 	"html/template"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/alecthomas/chroma/v2"
@@ -47,9 +50,61 @@ func LangFromFilename(filename string) Option {
 	}
 }
 
+// StripRegionMarkers removes region marker lines from the output of [Highlight].
+//
+// A region marker is a line that contains nothing but a comment with a marker of the form
+// [START name] or [END name], e.g.
+//
+//	// [START sort]
+func StripRegionMarkers() Option {
+	return func(o *highlighter) {
+		o.stripMarkers = true
+	}
+}
+
+// Region restricts the output of [Highlight] to the lines between the start and end marker of the
+// named region. If a region is started and ended multiple times, all parts of the region are part
+// of the output. All region markers are removed from the output (see [StripRegionMarkers]).
+func Region(name string) Option {
+	return func(o *highlighter) {
+		o.region = name
+		o.stripMarkers = true
+	}
+}
+
+// Callouts extracts callout markers from the ends of lines. A callout marker is a trailing comment
+// that contains nothing but one or more callout numbers in angle brackets, e.g.
+//
+//	sort.Ints(s) // <1>
+//
+// The markers are removed from the highlighted content and reported in [Line.Callouts].
+func Callouts() Option {
+	return func(o *highlighter) {
+		o.callouts = true
+	}
+}
+
+// DiffOptions sets the options used by [Diff] and [DiffHunks] to compute diffs. Without this
+// option, diffs are computed with [textdiff.IndentHeuristic].
+func DiffOptions(opts ...diff.Option) Option {
+	return func(o *highlighter) {
+		o.diffOpts = append([]diff.Option{}, opts...)
+	}
+}
+
+// StripCommon makes [Diff] and [DiffHunks] match the common prefix and suffix of both inputs
+// eagerly before computing the diff of the remaining lines. This is a common optimization, but it
+// can result in less readable diffs.
+func StripCommon() Option {
+	return func(o *highlighter) {
+		o.stripCommon = true
+	}
+}
+
 type Line struct {
-	LineNo  int
-	Content template.HTML
+	LineNo   int
+	Content  template.HTML
+	Callouts []int // callout numbers, only set with the [Callouts] option
 }
 
 func Highlight(in string, opts ...Option) ([]Line, error) {
@@ -60,8 +115,36 @@ func Highlight(in string, opts ...Option) ([]Line, error) {
 	}
 
 	ret := make([]Line, 0, len(lines))
+	found, inRegion := false, false
 	for i, line := range lines {
-		ret = append(ret, Line{i + 1, template.HTML(hl.highlight(line))})
+		if hl.stripMarkers {
+			if kind, name, ok := hl.marker(line); ok {
+				if name == hl.region {
+					switch {
+					case kind == "START" && inRegion:
+						return nil, fmt.Errorf("line %d: region %q started twice", i+1, name)
+					case kind == "END" && !inRegion:
+						return nil, fmt.Errorf("line %d: region %q ended before it started", i+1, name)
+					}
+					found, inRegion = true, kind == "START"
+				}
+				continue
+			}
+		}
+		if hl.region != "" && !inRegion {
+			continue
+		}
+		var callouts []int
+		if hl.callouts {
+			line, callouts = hl.extractCallouts(line)
+		}
+		ret = append(ret, Line{i + 1, template.HTML(hl.highlight(line)), callouts})
+	}
+	switch {
+	case hl.region != "" && !found:
+		return nil, fmt.Errorf("unknown region %q", hl.region)
+	case inRegion:
+		return nil, fmt.Errorf("region %q is never ended", hl.region)
 	}
 	return ret, nil
 }
@@ -79,60 +162,295 @@ func (ed *Edit) IsInsert() bool { return ed.Op == diff.Insert }
 
 func Diff(a, b string, opts ...Option) ([]Edit, error) {
 	hl := fromOptions(opts)
+	return hl.edits(hl.lineEdits(a, b))
+}
+
+// Hunk is a group of consecutive edits with unchanged context lines around them. Line numbers are
+// 1-based.
+type Hunk struct {
+	LineNoX, LinesX int
+	LineNoY, LinesY int
+
+	// Section is the optional section heading of the hunk, e.g. the enclosing function.
+	Section string
+
+	// Folded are the unchanged lines before the hunk that are outside of its context.
+	Folded []Edit
+
+	// Edits are the edits of the hunk including context. The last hunk of a diff may have no
+	// edits, if the files end in unchanged lines.
+	Edits []Edit
+}
+
+// Header returns the hunk header in unified diff format.
+func (h *Hunk) Header() string {
+	// Empty ranges start at the line before the range, by convention.
+	start := func(lineNo, n int) int {
+		if n == 0 {
+			return lineNo - 1
+		}
+		return lineNo
+	}
+	hdr := fmt.Sprintf("@@ -%d,%d +%d,%d @@", start(h.LineNoX, h.LinesX), h.LinesX, start(h.LineNoY, h.LinesY), h.LinesY)
+	if h.Section != "" {
+		hdr += " " + h.Section
+	}
+	return hdr
+}
+
+// DiffHunks is like [Diff], but groups the edits into hunks with context unchanged lines around
+// them. All unchanged lines outside of hunks are returned as folded lines.
+func DiffHunks(a, b string, context int, opts ...Option) ([]Hunk, error) {
+	hl := fromOptions(opts)
+	lines := slices.Collect(strings.Lines(a))
 
-	edits := textdiff.Edits(a, b, textdiff.IndentHeuristic())
+	// folded returns the unchanged lines a[x:end], starting at y in b.
+	folded := func(x, y, end int) ([]Edit, error) {
+		var ret []Edit
+		for ; x < end; x, y = x+1, y+1 {
+			tokens, err := hl.tokens(lines[x])
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, Edit{diff.Match, x + 1, y + 1, template.HTML(hl.highlight(tokens))})
+		}
+		return ret, nil
+	}
 
-	ret := make([]Edit, 0, len(edits))
+	var ret []Hunk
+	x, y := 0, 0
+	for _, h := range hl.lineHunks(a, b, context) {
+		f, err := folded(x, y, h.LineNoX)
+		if err != nil {
+			return nil, err
+		}
+		edits, err := hl.edits(h.Edits)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, Hunk{
+			LineNoX: h.LineNoX + 1,
+			LinesX:  h.EndLineNoX - h.LineNoX,
+			LineNoY: h.LineNoY + 1,
+			LinesY:  h.EndLineNoY - h.LineNoY,
+			Folded:  f,
+			Edits:   edits,
+		})
+		x, y = h.EndLineNoX, h.EndLineNoY
+	}
+	if x < len(lines) {
+		f, err := folded(x, y, len(lines))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, Hunk{Folded: f})
+	}
+	return ret, nil
+}
+
+func (hl *highlighter) diffOptions() []diff.Option {
+	if hl.diffOpts == nil {
+		return []diff.Option{textdiff.IndentHeuristic()}
+	}
+	return hl.diffOpts
+}
+
+// lineEdits computes the line-based edits to transform a into b.
+func (hl *highlighter) lineEdits(a, b string) []textdiff.Edit[string] {
+	if !hl.stripCommon {
+		return textdiff.Edits(a, b, hl.diffOptions()...)
+	}
+
+	x, y, prefix, suffix := splitCommon(a, b)
+	ret := make([]textdiff.Edit[string], 0, len(x)+len(y))
+	for i := range prefix {
+		ret = append(ret, textdiff.Edit[string]{Op: diff.Match, LineNoX: i, LineNoY: i, Line: x[i]})
+	}
+	midX, midY := strings.Join(x[prefix:len(x)-suffix], ""), strings.Join(y[prefix:len(y)-suffix], "")
+	for _, ed := range textdiff.Edits(midX, midY, hl.diffOptions()...) {
+		ret = append(ret, offsetEdit(ed, prefix))
+	}
+	for i := range suffix {
+		ix, iy := len(x)-suffix+i, len(y)-suffix+i
+		ret = append(ret, textdiff.Edit[string]{Op: diff.Match, LineNoX: ix, LineNoY: iy, Line: x[ix]})
+	}
+	return ret
+}
+
+// lineHunks computes the line-based hunks with context lines to transform a into b.
+func (hl *highlighter) lineHunks(a, b string, context int) []textdiff.Hunk[string] {
+	opts := append([]diff.Option{diff.Context(context)}, hl.diffOptions()...)
+	if !hl.stripCommon {
+		return textdiff.Hunks(a, b, opts...)
+	}
+
+	x, y, prefix, suffix := splitCommon(a, b)
+	midX, midY := strings.Join(x[prefix:len(x)-suffix], ""), strings.Join(y[prefix:len(y)-suffix], "")
+	hunks := textdiff.Hunks(midX, midY, opts...)
+	for i := range hunks {
+		h := &hunks[i]
+		h.LineNoX, h.EndLineNoX = h.LineNoX+prefix, h.EndLineNoX+prefix
+		h.LineNoY, h.EndLineNoY = h.LineNoY+prefix, h.EndLineNoY+prefix
+		for j := range h.Edits {
+			h.Edits[j] = offsetEdit(h.Edits[j], prefix)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil
+	}
+
+	// The context of the first and last hunk is cut off at the common prefix and suffix, extend
+	// them again.
+	first := &hunks[0]
+	n := min(context-leadingMatches(first.Edits), first.LineNoX)
+	for i := range max(n, 0) {
+		lx, ly := first.LineNoX-1-i, first.LineNoY-1-i
+		first.Edits = slices.Insert(first.Edits, 0, textdiff.Edit[string]{Op: diff.Match, LineNoX: lx, LineNoY: ly, Line: x[lx]})
+	}
+	first.LineNoX, first.LineNoY = first.LineNoX-max(n, 0), first.LineNoY-max(n, 0)
+
+	last := &hunks[len(hunks)-1]
+	rev := slices.Clone(last.Edits)
+	slices.Reverse(rev)
+	n = min(context-leadingMatches(rev), len(x)-last.EndLineNoX)
+	for i := range max(n, 0) {
+		lx, ly := last.EndLineNoX+i, last.EndLineNoY+i
+		last.Edits = append(last.Edits, textdiff.Edit[string]{Op: diff.Match, LineNoX: lx, LineNoY: ly, Line: x[lx]})
+	}
+	last.EndLineNoX, last.EndLineNoY = last.EndLineNoX+max(n, 0), last.EndLineNoY+max(n, 0)
+	return hunks
+}
+
+// splitCommon splits a and b into lines and returns the number of lines in their common prefix
+// and suffix. The prefix and suffix don't overlap.
+func splitCommon(a, b string) (x, y []string, prefix, suffix int) {
+	x, y = slices.Collect(strings.Lines(a)), slices.Collect(strings.Lines(b))
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	return x, y, prefix, suffix
+}
+
+// offsetEdit moves ed by n lines in both inputs.
+func offsetEdit(ed textdiff.Edit[string], n int) textdiff.Edit[string] {
+	if ed.LineNoX >= 0 {
+		ed.LineNoX += n
+	}
+	if ed.LineNoY >= 0 {
+		ed.LineNoY += n
+	}
+	return ed
+}
+
+// leadingMatches returns the number of matches at the start of edits.
+func leadingMatches(edits []textdiff.Edit[string]) int {
+	n := 0
+	for n < len(edits) && edits[n].Op == diff.Match {
+		n++
+	}
+	return n
+}
+
+// edits highlights the lines of the line-based edits.
+func (hl *highlighter) edits(edits []textdiff.Edit[string]) ([]Edit, error) {
+	tes := make([]tokenEdit, 0, len(edits))
 	for _, edit := range edits {
 		tokens, err := hl.tokens(edit.Line)
 		if err != nil {
 			return nil, err
 		}
-		ln := template.HTML(hl.highlight(tokens))
 		switch edit.Op {
 		case diff.Match:
-			ret = append(ret, Edit{edit.Op, edit.LineNoX + 1, edit.LineNoY + 1, ln})
+			tes = append(tes, tokenEdit{edit.Op, edit.LineNoX + 1, edit.LineNoY + 1, tokens})
 		case diff.Delete:
-			ret = append(ret, Edit{edit.Op, edit.LineNoX + 1, -1, ln})
+			tes = append(tes, tokenEdit{edit.Op, edit.LineNoX + 1, -1, tokens})
 		case diff.Insert:
-			ret = append(ret, Edit{edit.Op, -1, edit.LineNoY + 1, ln})
+			tes = append(tes, tokenEdit{edit.Op, -1, edit.LineNoY + 1, tokens})
 		}
 	}
-	return ret, nil
+	return hl.render(tes), nil
 }
 
-func ParseDiff(in string, opts ...Option) ([]Edit, error) {
-	hl := fromOptions(opts)
-	var ret []Edit
-	s, t := 0, 0
-	for l := range strings.Lines(in) {
-		var p byte
-		if len(l) > 0 && l[0] == '-' || l[0] == '+' || l[0] == ' ' {
-			p, l = l[0], l[1:]
-		}
-		tokens, err := hl.tokens(l)
-		if err != nil {
-			return nil, err
+// tokenEdit is an edit of a tokenized line.
+type tokenEdit struct {
+	op      diff.Op
+	lineNoX int
+	lineNoY int
+	tokens  []chroma.Token
+}
+
+// render highlights the lines of edits.
+//
+// Deleted lines directly followed by inserted lines are paired up and diffed again on the level of
+// tokens. Changed tokens in those lines are wrapped in <del> or <ins>.
+func (hl *highlighter) render(edits []tokenEdit) []Edit {
+	changed := make([][]bool, len(edits))
+	for i := 0; i < len(edits); {
+		if edits[i].op != diff.Delete {
+			i++
+			continue
+		}
+		j := i
+		for j < len(edits) && edits[j].op == diff.Delete {
+			j++
 		}
-		ln := template.HTML(hl.highlight(tokens))
-		switch p {
-		default:
-			ret = append(ret, Edit{diff.Match, s + 1, t + 1, ln})
-			s++
-			t++
-		case '-':
-			ret = append(ret, Edit{diff.Delete, s + 1, -1, ln})
-			s++
-		case '+':
-			ret = append(ret, Edit{diff.Insert, -1, t + 1, ln})
-			t++
+		k := j
+		for k < len(edits) && edits[k].op == diff.Insert {
+			k++
 		}
+		for p := 0; p < j-i && p < k-j; p++ {
+			changed[i+p], changed[j+p] = changedTokens(edits[i+p].tokens, edits[j+p].tokens)
+		}
+		i = k
 	}
-	return ret, nil
+
+	ret := make([]Edit, len(edits))
+	for i, ed := range edits {
+		tag := ""
+		switch ed.op {
+		case diff.Delete:
+			tag = "del"
+		case diff.Insert:
+			tag = "ins"
+		}
+		ret[i] = Edit{ed.op, ed.lineNoX, ed.lineNoY, template.HTML(hl.highlightChanged(ed.tokens, changed[i], tag))}
+	}
+	return ret
+}
+
+// changedTokens diffs the tokens of the lines x and y and reports which tokens changed. It returns
+// nil if the lines have nothing but whitespace in common, marking every token as changed doesn't
+// help readers.
+func changedTokens(x, y []chroma.Token) (cx, cy []bool) {
+	cx, cy = make([]bool, len(x)), make([]bool, len(y))
+	common := false
+	for _, ed := range diff.Edits(x, y) {
+		switch ed.Op {
+		case diff.Match:
+			common = common || strings.TrimSpace(ed.X.Value) != ""
+		case diff.Delete:
+			cx[ed.PosX] = true
+		case diff.Insert:
+			cy[ed.PosY] = true
+		}
+	}
+	if !common {
+		return nil, nil
+	}
+	return cx, cy
 }
 
 type highlighter struct {
-	lexer chroma.Lexer
+	lexer        chroma.Lexer
+	plain        bool // true if the lexer doesn't know the language
+	stripMarkers bool
+	region       string
+	callouts     bool
+	diffOpts     []diff.Option // nil for the default options
+	stripCommon  bool
 }
 
 func fromOptions(opts []Option) *highlighter {
@@ -147,13 +465,28 @@ func fromOptions(opts []Option) *highlighter {
 	if hl.lexer == nil {
 		hl.lexer = lexers.Fallback
 	}
+	hl.plain = hl.lexer == lexers.Fallback
 	hl.lexer = chroma.Coalesce(hl.lexer)
 	return hl
 }
 
 func (hl *highlighter) highlight(line []chroma.Token) string {
+	return hl.highlightChanged(line, nil, "")
+}
+
+// highlightChanged highlights line and wraps all tokens that are marked as changed in tag.
+func (hl *highlighter) highlightChanged(line []chroma.Token, changed []bool, tag string) string {
 	var sb strings.Builder
-	for _, token := range line {
+	open := false
+	for i, token := range line {
+		if c := changed != nil && changed[i]; c != open {
+			if c {
+				fmt.Fprintf(&sb, "<%s>", tag)
+			} else {
+				fmt.Fprintf(&sb, "</%s>", tag)
+			}
+			open = c
+		}
 		class := class(token.Type)
 		if class != "" {
 			fmt.Fprintf(&sb, "<span class=\"%s\">", class)
@@ -163,6 +496,9 @@ func (hl *highlighter) highlight(line []chroma.Token) string {
 			fmt.Fprintf(&sb, "</span>")
 		}
 	}
+	if open {
+		fmt.Fprintf(&sb, "</%s>", tag)
+	}
 	return sb.String()
 }
 
@@ -182,6 +518,88 @@ func (hl *highlighter) lines(in string) ([][]chroma.Token, error) {
 	return chroma.SplitTokensIntoLines(it.Tokens()), nil
 }
 
+var markerRe = regexp.MustCompile(`\[(START|END) ([^\]\s]+)\]`)
+
+// marker reports whether line is a region marker, and if so, returns whether it's a START or END
+// marker and the name of the region.
+func (hl *highlighter) marker(line []chroma.Token) (kind, name string, ok bool) {
+	var comment strings.Builder
+	for _, token := range line {
+		switch {
+		case hl.plain || token.Type.InCategory(chroma.Comment):
+			comment.WriteString(token.Value)
+		case strings.TrimSpace(token.Value) != "":
+			return "", "", false
+		}
+	}
+	m := markerRe.FindStringSubmatch(comment.String())
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+var (
+	calloutCommentRe = regexp.MustCompile(`^(?://|#|--|;|/\*|<!--)\s*((?:<\d+>\s*)+)(?:\*/|-->)?\s*$`)
+	calloutPlainRe   = regexp.MustCompile(`\s*(?://|#|--|;)\s*((?:<\d+>\s*)+)$`)
+	calloutNumberRe  = regexp.MustCompile(`<(\d+)>`)
+)
+
+// extractCallouts removes a trailing callout marker from line and returns the callout numbers.
+func (hl *highlighter) extractCallouts(line []chroma.Token) ([]chroma.Token, []int) {
+	last := -1
+	for i, token := range line {
+		if strings.TrimSpace(token.Value) != "" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return line, nil
+	}
+
+	token := line[last]
+	var numbers string
+	switch {
+	case hl.plain:
+		value := strings.TrimRight(token.Value, "\r\n")
+		loc := calloutPlainRe.FindStringSubmatchIndex(value)
+		if loc == nil {
+			return line, nil
+		}
+		numbers = value[loc[2]:loc[3]]
+		token.Value = value[:loc[0]] + token.Value[len(value):]
+	case token.Type.InCategory(chroma.Comment):
+		m := calloutCommentRe.FindStringSubmatch(token.Value)
+		if m == nil {
+			return line, nil
+		}
+		numbers = m[1]
+		token.Value = ""
+	default:
+		return line, nil
+	}
+
+	var callouts []int
+	for _, m := range calloutNumberRe.FindAllStringSubmatch(numbers, -1) {
+		n, _ := strconv.Atoi(m[1])
+		callouts = append(callouts, n)
+	}
+
+	// Rebuild the line without the marker and the whitespace in front of it.
+	ret := slices.Clone(line[:last])
+	for len(ret) > 0 && strings.TrimSpace(ret[len(ret)-1].Value) == "" && !strings.Contains(ret[len(ret)-1].Value, "\n") {
+		ret = ret[:len(ret)-1]
+	}
+	if len(ret) > 0 {
+		ret[len(ret)-1].Value = strings.TrimRight(ret[len(ret)-1].Value, " \t")
+	}
+	if token.Value != "" {
+		ret = append(ret, token)
+	}
+	ret = append(ret, line[last+1:]...)
+	return ret, callouts
+}
+
 func class(t chroma.TokenType) string {
 	s, ok := style[t]
 	if ok {
@@ -0,0 +1,400 @@
+package highlight
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func lineNos(lines []Line) []int {
+	var ret []int
+	for _, l := range lines {
+		ret = append(ret, l.LineNo)
+	}
+	return ret
+}
+
+func TestHighlight_Region(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		in   string
+		opts []Option
+		want []int
+	}{
+		{
+			name: "go",
+			file: "a.go",
+			in: `package a
+
+// [START sort]
+func Sort() {
+	// [START body]
+	return
+	// [END body]
+}
+// [END sort]
+`,
+			opts: []Option{Region("sort")},
+			want: []int{4, 6, 8},
+		},
+		{
+			name: "go_block_comment",
+			file: "a.go",
+			in:   "a\n/* [START x] */\nb\n/* [END x] */\nc\n",
+			opts: []Option{Region("x")},
+			want: []int{3},
+		},
+		{
+			name: "shell",
+			file: "a.sh",
+			in:   "#!/bin/sh\n# [START x]\necho hello\n# [END x]\n",
+			opts: []Option{Region("x")},
+			want: []int{3},
+		},
+		{
+			name: "html",
+			file: "a.html",
+			in:   "<html>\n<!-- [START x] -->\n<p>hello</p>\n<!-- [END x] -->\n</html>\n",
+			opts: []Option{Region("x")},
+			want: []int{3},
+		},
+		{
+			name: "plain_text",
+			file: "a.unknown-extension",
+			in:   "a\n-- [START x]\nb\n-- [END x]\nc\n",
+			opts: []Option{Region("x")},
+			want: []int{3},
+		},
+		{
+			name: "multiple_parts",
+			file: "a.go",
+			in:   "// [START x]\na\n// [END x]\nb\n// [START x]\nc\n// [END x]\n",
+			opts: []Option{Region("x")},
+			want: []int{2, 6},
+		},
+		{
+			name: "strip_markers",
+			file: "a.go",
+			in:   "a\n// [START x]\nb\n// [END x]\nc",
+			opts: []Option{StripRegionMarkers()},
+			want: []int{1, 3, 5},
+		},
+		{
+			name: "keep_markers",
+			file: "a.go",
+			in:   "a\n// [START x]\nb\n// [END x]\nc",
+			want: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name: "marker_with_code_is_no_marker",
+			file: "a.go",
+			in:   "// [START x]\nreturn // [END x]\n// [END x]\n",
+			opts: []Option{Region("x")},
+			want: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Highlight(tt.in, append([]Option{LangFromFilename(tt.file)}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, lineNos(got)); diff != "" {
+				t.Errorf("line numbers mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHighlight_RegionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			name:    "unknown",
+			in:      "// [START x]\na\n// [END x]\n",
+			wantErr: `unknown region "y"`,
+		},
+		{
+			name:    "unterminated",
+			in:      "// [START y]\na\n",
+			wantErr: `region "y" is never ended`,
+		},
+		{
+			name:    "end_before_start",
+			in:      "// [END y]\na\n",
+			wantErr: `region "y" ended before it started`,
+		},
+		{
+			name:    "started_twice",
+			in:      "// [START y]\n// [START y]\n",
+			wantErr: `region "y" started twice`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Highlight(tt.in, Lang("go"), Region("y"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Highlight() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHighlight_Callouts(t *testing.T) {
+	tests := []struct {
+		name         string
+		lang         string
+		in           string
+		wantCallouts [][]int
+		wantContent  []string
+	}{
+		{
+			name:         "go",
+			lang:         "go",
+			in:           "a := 1 // <1>\nb := 2\nc := 3 // <2> <3>\n",
+			wantCallouts: [][]int{{1}, nil, {2, 3}},
+			wantContent:  []string{"a := 1\n", "b := 2\n", "c := 3\n"},
+		},
+		{
+			name:         "go_block_comment",
+			lang:         "go",
+			in:           "a := 1 /* <1> */\n",
+			wantCallouts: [][]int{{1}},
+			wantContent:  []string{"a := 1\n"},
+		},
+		{
+			name:         "regular_comment",
+			lang:         "go",
+			in:           "a := 1 // a <1>\nb := a < 1\n",
+			wantCallouts: [][]int{nil, nil},
+			wantContent:  []string{"a := 1 // a <1>\n", "b := a < 1\n"},
+		},
+		{
+			name:         "shell",
+			lang:         "bash",
+			in:           "echo hello # <1>\n",
+			wantCallouts: [][]int{{1}},
+			wantContent:  []string{"echo hello\n"},
+		},
+		{
+			name:         "plain",
+			lang:         "unknown-language",
+			in:           "hello # <4>\n",
+			wantCallouts: [][]int{{4}},
+			wantContent:  []string{"hello\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Highlight(tt.in, Lang(tt.lang), Callouts())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var gotCallouts [][]int
+			var gotContent []string
+			for _, l := range lines {
+				gotCallouts = append(gotCallouts, l.Callouts)
+				gotContent = append(gotContent, html.UnescapeString(tagRe.ReplaceAllString(string(l.Content), "")))
+			}
+			if diff := cmp.Diff(tt.wantCallouts, gotCallouts); diff != "" {
+				t.Errorf("callouts mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantContent, gotContent); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+
+func TestDiffHunks(t *testing.T) {
+	var a, b strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&a, "line %d\n", i)
+		if i == 10 {
+			fmt.Fprintf(&b, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+	}
+
+	hunks, err := DiffHunks(a.String(), b.String(), 2, Lang("text"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	type hunk struct {
+		Header string
+		Folded []int
+		Edits  []string
+	}
+	var got []hunk
+	for _, h := range hunks {
+		g := hunk{Folded: lineNosX(h.Folded)}
+		if len(h.Edits) > 0 {
+			g.Header = h.Header()
+		}
+		for _, ed := range h.Edits {
+			g.Edits = append(g.Edits, fmt.Sprintf("%v %d %d", ed.Op, ed.LineNoX, ed.LineNoY))
+		}
+		got = append(got, g)
+	}
+	want := []hunk{
+		{
+			Header: "@@ -8,5 +8,5 @@",
+			Folded: []int{1, 2, 3, 4, 5, 6, 7},
+			Edits:  []string{"Match 8 8", "Match 9 9", "Delete 10 -1", "Insert -1 10", "Match 11 11", "Match 12 12"},
+		},
+		{
+			Folded: []int{13, 14, 15, 16, 17, 18, 19, 20},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("hunks mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func lineNosX(edits []Edit) []int {
+	var ret []int
+	for _, ed := range edits {
+		ret = append(ret, ed.LineNoX)
+	}
+	return ret
+}
+
+func TestDiff_Intraline(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "changed_identifier",
+			a:    "x := foo(a, b)\n",
+			b:    "x := bar(a, b)\n",
+			want: []string{"x := <del>foo</del>(a, b)\n", "x := <ins>bar</ins>(a, b)\n"},
+		},
+		{
+			name: "nothing_in_common",
+			a:    "foo\n",
+			b:    "bar\n",
+			want: []string{"foo\n", "bar\n"},
+		},
+		{
+			name: "unpaired_insert",
+			a:    "x := foo\n",
+			b:    "x := bar\ny := baz\n",
+			want: []string{"x := <del>foo</del>\n", "x := <ins>bar</ins>\n", "y := baz\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits, err := Diff(tt.a, tt.b, Lang("go"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, ed := range edits {
+				got = append(got, html.UnescapeString(spanRe.ReplaceAllString(string(ed.Content), "")))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseDiff_Intraline(t *testing.T) {
+	files, err := ParseDiff(" a\n-x := foo\n+x := bar\n", Lang("go"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, ed := range files[0].Hunks[0].Edits {
+		got = append(got, html.UnescapeString(spanRe.ReplaceAllString(string(ed.Content), "")))
+	}
+	want := []string{"a\n", "x := <del>foo</del>\n", "x := <ins>bar</ins>\n"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("content mismatch (-want +got):\n%s", diff)
+	}
+}
+
+var spanRe = regexp.MustCompile(`</?span[^>]*>`)
+
+func TestDiff_Options(t *testing.T) {
+	// From the diff article: stripping the common prefix and suffix eagerly results in a less
+	// readable diff.
+	a := "{\n    name: \"Freak Out!\",\n},\n{\n    name: \"Money\",\n},\n"
+	b := "{\n    name: \"Freak Out!\",\n},\n{\n    name: \"Free\",\n},\n{\n    name: \"Money\",\n},\n"
+
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{"  {", "      name: \"Freak Out!\",", "  },", "+ {", "+     name: \"Free\",", "+ },", "  {", "      name: \"Money\",", "  },"},
+		},
+		{
+			name: "strip_common",
+			opts: []Option{StripCommon()},
+			want: []string{"  {", "      name: \"Freak Out!\",", "  },", "  {", "+     name: \"Free\",", "+ },", "+ {", "      name: \"Money\",", "  },"},
+		},
+		{
+			name: "no_indent_heuristic",
+			opts: []Option{DiffOptions()},
+			want: []string{"  {", "      name: \"Freak Out!\",", "  },", "  {", "+     name: \"Free\",", "+ },", "+ {", "      name: \"Money\",", "  },"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits, err := Diff(a, b, append([]Option{Lang("text")}, tt.opts...)...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, ed := range edits {
+				op := map[string]string{"Match": " ", "Delete": "-", "Insert": "+"}[ed.Op.String()]
+				got = append(got, op+" "+strings.TrimSuffix(html.UnescapeString(tagRe.ReplaceAllString(string(ed.Content), "")), "\n"))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Diff() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiffHunks_StripCommon(t *testing.T) {
+	var a, b strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&a, "line %d\n", i)
+		fmt.Fprintf(&b, "line %d\n", i)
+		if i == 5 {
+			fmt.Fprintf(&b, "new\n")
+		}
+	}
+	for _, opts := range [][]Option{nil, {StripCommon()}} {
+		hunks, err := DiffHunks(a.String(), b.String(), 2, append([]Option{Lang("text")}, opts...)...)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := hunks[0].Header(), "@@ -4,4 +4,5 @@"; got != want {
+			t.Errorf("DiffHunks(%d options) header = %q, want %q", len(opts), got, want)
+		}
+		if got, want := lineNosX(hunks[0].Folded), []int{1, 2, 3}; !slices.Equal(got, want) {
+			t.Errorf("DiffHunks(%d options) folded = %v, want %v", len(opts), got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package highlight
+
+import (
+	"html/template"
+	"slices"
+	"strings"
+
+	"znkr.io/diff"
+	"znkr.io/diff/textdiff"
+)
+
+// MergeKind is the kind of a [MergeChunk].
+type MergeKind int
+
+const (
+	Unchanged   MergeKind = iota // neither side changed the lines
+	ChangedA                     // only a changed the lines
+	ChangedB                     // only b changed the lines
+	ChangedBoth                  // both sides changed the lines in the same way
+	Conflict                     // both sides changed the lines in different ways
+)
+
+// MergeLine is a line of a three-way merge. Line numbers are 1-based and 0 if the line isn't part of
+// the respective file.
+type MergeLine struct {
+	Op         diff.Op // Delete for lines removed from base, Insert for added lines, Match otherwise
+	LineNoBase int
+	LineNoA    int
+	LineNoB    int
+	Content    template.HTML
+}
+
+func (l *MergeLine) IsMatch() bool  { return l.Op == diff.Match }
+func (l *MergeLine) IsDelete() bool { return l.Op == diff.Delete }
+func (l *MergeLine) IsInsert() bool { return l.Op == diff.Insert }
+
+// MergeChunk is a group of consecutive lines of a three-way merge.
+type MergeChunk struct {
+	Kind MergeKind
+
+	// Lines transform base into the merged result: Unchanged lines, or the deleted base lines
+	// followed by the inserted lines of the changed side. Not set for conflicts.
+	Lines []MergeLine
+
+	// A, Base, and B are the lines of each file in a conflict, only set for conflicts.
+	A, Base, B []MergeLine
+}
+
+func (c *MergeChunk) IsUnchanged() bool   { return c.Kind == Unchanged }
+func (c *MergeChunk) IsChangedA() bool    { return c.Kind == ChangedA }
+func (c *MergeChunk) IsChangedB() bool    { return c.Kind == ChangedB }
+func (c *MergeChunk) IsChangedBoth() bool { return c.Kind == ChangedBoth }
+func (c *MergeChunk) IsConflict() bool    { return c.Kind == Conflict }
+
+// Merge computes a three-way merge of a and b, both derived from base.
+//
+// Both sides are diffed against base. Changes of a and b that overlap or touch each other in base
+// are grouped into a single chunk, which is a conflict unless both sides changed the lines in the
+// same way.
+func Merge(base, a, b string, opts ...Option) ([]MergeChunk, error) {
+	hl := fromOptions(opts)
+	baseLines := slices.Collect(strings.Lines(base))
+	sa := newMergeSide(hl.lineEdits(base, a), len(baseLines))
+	sb := newMergeSide(hl.lineEdits(base, b), len(baseLines))
+
+	// Changes are located in slots: Slot 2i holds the lines inserted before base line i and slot
+	// 2i+1 holds base line i. Consecutive changed slots form a chunk.
+	nslots := 2*len(baseLines) + 1
+	changed := func(slot int) bool { return sa.changed(slot) || sb.changed(slot) }
+
+	var ret []MergeChunk
+	var unchanged []mergeEdit
+	flush := func() error {
+		if len(unchanged) == 0 {
+			return nil
+		}
+		lines, err := hl.mergeLines(unchanged)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, MergeChunk{Kind: Unchanged, Lines: lines})
+		unchanged = nil
+		return nil
+	}
+
+	for slot := 0; slot < nslots; {
+		if !changed(slot) {
+			if slot%2 == 1 {
+				i := slot / 2
+				unchanged = append(unchanged, mergeEdit{diff.Match, i + 1, sa.match[i] + 1, sb.match[i] + 1, baseLines[i]})
+			}
+			slot++
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		end := slot
+		for end < nslots && changed(end) {
+			end++
+		}
+		chunk, err := hl.mergeChunk(baseLines, sa, sb, slot, end)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, chunk)
+		slot = end
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// mergeChunk computes the chunk for the changed slots [from, to).
+func (hl *highlighter) mergeChunk(baseLines []string, sa, sb *mergeSide, from, to int) (MergeChunk, error) {
+	var changedA, changedB bool
+	var base, a, b []mergeEdit
+	for slot := from; slot < to; slot++ {
+		changedA = changedA || sa.changed(slot)
+		changedB = changedB || sb.changed(slot)
+		if slot%2 == 1 {
+			i := slot / 2
+			base = append(base, mergeEdit{diff.Delete, i + 1, 0, 0, baseLines[i]})
+		}
+		for _, j := range sa.lines(slot) {
+			a = append(a, mergeEdit{diff.Insert, 0, j + 1, 0, sa.text[j]})
+		}
+		for _, j := range sb.lines(slot) {
+			b = append(b, mergeEdit{diff.Insert, 0, 0, j + 1, sb.text[j]})
+		}
+	}
+
+	switch {
+	case changedA && changedB && slices.EqualFunc(a, b, func(x, y mergeEdit) bool { return x.line == y.line }):
+		for i := range a {
+			a[i].lineNoB = b[i].lineNoB
+		}
+		return hl.changedChunk(ChangedBoth, base, a)
+	case changedA && changedB:
+		// Conflicting lines are shown as they are, there's nothing to compare them with.
+		for _, edits := range [][]mergeEdit{a, base, b} {
+			for i := range edits {
+				edits[i].op = diff.Match
+			}
+		}
+		la, err := hl.mergeLines(a)
+		if err != nil {
+			return MergeChunk{}, err
+		}
+		lbase, err := hl.mergeLines(base)
+		if err != nil {
+			return MergeChunk{}, err
+		}
+		lb, err := hl.mergeLines(b)
+		if err != nil {
+			return MergeChunk{}, err
+		}
+		return MergeChunk{Kind: Conflict, A: la, Base: lbase, B: lb}, nil
+	case changedA:
+		return hl.changedChunk(ChangedA, base, a)
+	default:
+		return hl.changedChunk(ChangedB, base, b)
+	}
+}
+
+// changedChunk returns a chunk that replaces the base lines with ins.
+func (hl *highlighter) changedChunk(kind MergeKind, base, ins []mergeEdit) (MergeChunk, error) {
+	lines, err := hl.mergeLines(append(base, ins...))
+	if err != nil {
+		return MergeChunk{}, err
+	}
+	return MergeChunk{Kind: kind, Lines: lines}, nil
+}
+
+// mergeEdit is a line of a three-way merge before highlighting.
+type mergeEdit struct {
+	op         diff.Op
+	lineNoBase int
+	lineNoA    int
+	lineNoB    int
+	line       string
+}
+
+// mergeLines highlights the lines of edits.
+func (hl *highlighter) mergeLines(edits []mergeEdit) ([]MergeLine, error) {
+	tes := make([]tokenEdit, len(edits))
+	for i, ed := range edits {
+		tokens, err := hl.tokens(ed.line)
+		if err != nil {
+			return nil, err
+		}
+		tes[i] = tokenEdit{op: ed.op, tokens: tokens}
+	}
+	rendered := hl.render(tes)
+	ret := make([]MergeLine, len(edits))
+	for i, ed := range edits {
+		ret[i] = MergeLine{ed.op, ed.lineNoBase, ed.lineNoA, ed.lineNoB, rendered[i].Content}
+	}
+	return ret, nil
+}
+
+// mergeSide is one side of a three-way merge, derived from the edits that transform base into
+// this side. All indices are 0-based.
+type mergeSide struct {
+	text  []string // lines of this side
+	match []int    // index of each base line in this side, -1 if it was deleted
+	ins   [][]int  // indices of the lines inserted before each base line, or at the end
+}
+
+func newMergeSide(edits []textdiff.Edit[string], nbase int) *mergeSide {
+	s := &mergeSide{
+		match: make([]int, nbase),
+		ins:   make([][]int, nbase+1),
+	}
+	i := 0 // position in base
+	for _, ed := range edits {
+		switch ed.Op {
+		case diff.Match:
+			s.match[ed.LineNoX] = ed.LineNoY
+			s.text = append(s.text, ed.Line)
+			i = ed.LineNoX + 1
+		case diff.Delete:
+			s.match[ed.LineNoX] = -1
+			i = ed.LineNoX + 1
+		case diff.Insert:
+			s.ins[i] = append(s.ins[i], ed.LineNoY)
+			s.text = append(s.text, ed.Line)
+		}
+	}
+	return s
+}
+
+// changed reports whether this side changed the slot (see [Merge]).
+func (s *mergeSide) changed(slot int) bool {
+	if slot%2 == 0 {
+		return len(s.ins[slot/2]) > 0
+	}
+	return s.match[slot/2] < 0
+}
+
+// lines returns the indices of the lines of this side in slot.
+func (s *mergeSide) lines(slot int) []int {
+	if slot%2 == 0 {
+		return s.ins[slot/2]
+	}
+	if j := s.match[slot/2]; j >= 0 {
+		return []int{j}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package highlight
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// mergeSummary describes a merge chunk as one string per line.
+func mergeSummary(chunks []MergeChunk) []string {
+	kinds := map[MergeKind]string{
+		Unchanged:   "unchanged",
+		ChangedA:    "changed-a",
+		ChangedB:    "changed-b",
+		ChangedBoth: "changed-both",
+		Conflict:    "conflict",
+	}
+	var ret []string
+	add := func(prefix string, lines []MergeLine) {
+		for _, l := range lines {
+			op := " "
+			switch {
+			case l.IsDelete():
+				op = "-"
+			case l.IsInsert():
+				op = "+"
+			}
+			text := strings.TrimSuffix(html.UnescapeString(spanRe.ReplaceAllString(string(l.Content), "")), "\n")
+			ret = append(ret, fmt.Sprintf("%s%s %d %d %d %s", prefix, op, l.LineNoBase, l.LineNoA, l.LineNoB, text))
+		}
+	}
+	for _, c := range chunks {
+		ret = append(ret, kinds[c.Kind])
+		add("", c.Lines)
+		add("a", c.A)
+		add("base", c.Base)
+		add("b", c.B)
+	}
+	return ret
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name       string
+		base, a, b string
+		want       []string
+	}{
+		{
+			name: "unchanged",
+			base: "1\n2\n",
+			a:    "1\n2\n",
+			b:    "1\n2\n",
+			want: []string{
+				"unchanged",
+				"  1 1 1 1",
+				"  2 2 2 2",
+			},
+		},
+		{
+			name: "separate_changes",
+			base: "1\n2\n3\n4\n5\n",
+			a:    "1\nA\n3\n4\n5\n",
+			b:    "1\n2\n3\n5\nB\n",
+			want: []string{
+				"unchanged",
+				"  1 1 1 1",
+				"changed-a",
+				"- 2 0 0 2",
+				"+ 0 2 0 A",
+				"unchanged",
+				"  3 3 3 3",
+				"changed-b",
+				"- 4 0 0 4",
+				"unchanged",
+				"  5 5 4 5",
+				"changed-b",
+				"+ 0 0 5 B",
+			},
+		},
+		{
+			name: "same_change",
+			base: "1\n2\n3\n",
+			a:    "1\nX\n3\n",
+			b:    "1\nX\n3\n",
+			want: []string{
+				"unchanged",
+				"  1 1 1 1",
+				"changed-both",
+				"- 2 0 0 2",
+				"+ 0 2 2 X",
+				"unchanged",
+				"  3 3 3 3",
+			},
+		},
+		{
+			name: "conflict",
+			base: "1\n2\n3\n",
+			a:    "1\nA\n3\n",
+			b:    "1\nB\nB\n3\n",
+			want: []string{
+				"unchanged",
+				"  1 1 1 1",
+				"conflict",
+				"a  0 2 0 A",
+				"base  2 0 0 2",
+				"b  0 0 2 B",
+				"b  0 0 3 B",
+				"unchanged",
+				"  3 3 4 3",
+			},
+		},
+		{
+			name: "adjacent_changes_conflict",
+			base: "1\n2\n3\n",
+			a:    "1\n3\n",
+			b:    "1\n2\nB\n3\n",
+			want: []string{
+				"unchanged",
+				"  1 1 1 1",
+				"conflict",
+				"base  2 0 0 2",
+				"b  0 0 2 2",
+				"b  0 0 3 B",
+				"unchanged",
+				"  3 2 4 3",
+			},
+		},
+		{
+			name: "insert_and_delete",
+			base: "1\n2\n",
+			a:    "0\n1\n2\n",
+			b:    "1\n",
+			want: []string{
+				"changed-a",
+				"+ 0 1 0 0",
+				"unchanged",
+				"  1 2 1 1",
+				"changed-b",
+				"- 2 0 0 2",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := Merge(tt.base, tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, mergeSummary(chunks)); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
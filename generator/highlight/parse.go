@@ -0,0 +1,209 @@
+package highlight
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"znkr.io/diff"
+)
+
+// FileDiff is the diff of a single file in a patch.
+type FileDiff struct {
+	OldName, NewName string // empty if the patch has no file headers
+
+	// Hunks are the hunks of the file. Patches without hunk headers have a single hunk that
+	// contains all lines.
+	Hunks []Hunk
+
+	// HasHunkHeaders reports whether the hunks were parsed from hunk headers.
+	HasHunkHeaders bool
+}
+
+// Name returns the name of the file, or an empty string if the patch has no file headers.
+func (fd *FileDiff) Name() string {
+	if fd.NewName == "" || fd.NewName == "/dev/null" {
+		return fd.OldName
+	}
+	return fd.NewName
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseDiff parses a patch in unified or git diff format and highlights all lines.
+//
+// The language of each file is detected from the file headers, unless it's set by an option. As a
+// special case, a patch without hunk headers is interpreted as a single hunk of a single file that
+// starts at line 1.
+func ParseDiff(in string, opts ...Option) ([]FileDiff, error) {
+	if !hasHunkHeader(in) {
+		return parseBareDiff(in, opts)
+	}
+
+	var files []FileDiff
+	var file *FileDiff
+	var tes []tokenEdit
+	var hl *highlighter
+	x, y := 0, 0   // next line numbers
+	nx, ny := 0, 0 // remaining lines in current hunk
+	newFile := func() {
+		files = append(files, FileDiff{HasHunkHeaders: true})
+		file = &files[len(files)-1]
+		hl = nil
+	}
+	endHunk := func() {
+		if file != nil && len(file.Hunks) > 0 {
+			h := &file.Hunks[len(file.Hunks)-1]
+			h.Edits = hl.render(tes)
+		}
+		tes = nil
+	}
+
+	lineNo := 0
+	for l := range strings.Lines(in) {
+		lineNo++
+		l = strings.TrimSuffix(l, "\n")
+
+		if nx > 0 || ny > 0 {
+			op := byte(' ')
+			if l != "" { // some tools strip trailing whitespace from empty context lines
+				op, l = l[0], l[1:]
+			}
+			tokens, err := hl.tokens(l + "\n")
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case op == ' ' && nx > 0 && ny > 0:
+				tes = append(tes, tokenEdit{diff.Match, x, y, tokens})
+				x, y, nx, ny = x+1, y+1, nx-1, ny-1
+			case op == '-' && nx > 0:
+				tes = append(tes, tokenEdit{diff.Delete, x, -1, tokens})
+				x, nx = x+1, nx-1
+			case op == '+' && ny > 0:
+				tes = append(tes, tokenEdit{diff.Insert, -1, y, tokens})
+				y, ny = y+1, ny-1
+			case op == '\\':
+				// "\ No newline at end of file"
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", lineNo, string(op)+l)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			endHunk()
+			newFile()
+			if a, b, ok := strings.Cut(l[len("diff --git "):], " b/"); ok {
+				file.OldName, file.NewName = strings.TrimPrefix(a, "a/"), b
+			}
+		case strings.HasPrefix(l, "--- "):
+			endHunk()
+			if file == nil || len(file.Hunks) > 0 {
+				newFile()
+			}
+			file.OldName = headerName(l[len("--- "):], "a/")
+		case strings.HasPrefix(l, "+++ ") && file != nil && len(file.Hunks) == 0:
+			file.NewName = headerName(l[len("+++ "):], "b/")
+		case strings.HasPrefix(l, "@@ "):
+			m := hunkHeaderRe.FindStringSubmatch(l)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header: %q", lineNo, l)
+			}
+			if file == nil {
+				newFile()
+			}
+			endHunk()
+			if hl == nil {
+				hl = fromOptions(append([]Option{LangFromFilename(file.Name())}, opts...))
+			}
+			x, nx = hunkRange(m[1], m[2])
+			y, ny = hunkRange(m[3], m[4])
+			file.Hunks = append(file.Hunks, Hunk{
+				LineNoX: x,
+				LinesX:  nx,
+				LineNoY: y,
+				LinesY:  ny,
+				Section: m[5],
+			})
+		case strings.HasPrefix(l, "\\"):
+			// "\ No newline at end of file" after the last line of a hunk.
+		default:
+			// Other header lines (index, mode changes, renames, commit messages, etc.) are
+			// ignored.
+		}
+	}
+	if nx > 0 || ny > 0 {
+		return nil, fmt.Errorf("unexpected end of diff, hunk is missing %d old and %d new lines", nx, ny)
+	}
+	endHunk()
+	return files, nil
+}
+
+// hasHunkHeader reports whether in contains at least one hunk header.
+func hasHunkHeader(in string) bool {
+	for l := range strings.Lines(in) {
+		if hunkHeaderRe.MatchString(strings.TrimSuffix(l, "\n")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkRange parses the start and length of a range in a hunk header. The length defaults to 1.
+func hunkRange(start, length string) (lineNo, n int) {
+	lineNo, _ = strconv.Atoi(start)
+	n = 1
+	if length != "" {
+		n, _ = strconv.Atoi(length)
+	}
+	if n == 0 {
+		// An empty range starts after the given line.
+		lineNo++
+	}
+	return lineNo, n
+}
+
+// headerName returns the file name in a ---/+++ header line, stripping timestamps and the
+// prefix git adds to file names.
+func headerName(s, prefix string) string {
+	if name, _, ok := strings.Cut(s, "\t"); ok {
+		s = name
+	}
+	if s == "/dev/null" {
+		return s
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// parseBareDiff parses a diff without any headers, every line starts with the op.
+func parseBareDiff(in string, opts []Option) ([]FileDiff, error) {
+	hl := fromOptions(opts)
+	var tes []tokenEdit
+	s, t := 0, 0
+	for l := range strings.Lines(in) {
+		var p byte
+		if len(l) > 0 && l[0] == '-' || l[0] == '+' || l[0] == ' ' {
+			p, l = l[0], l[1:]
+		}
+		tokens, err := hl.tokens(l)
+		if err != nil {
+			return nil, err
+		}
+		switch p {
+		default:
+			tes = append(tes, tokenEdit{diff.Match, s + 1, t + 1, tokens})
+			s++
+			t++
+		case '-':
+			tes = append(tes, tokenEdit{diff.Delete, s + 1, -1, tokens})
+			s++
+		case '+':
+			tes = append(tes, tokenEdit{diff.Insert, -1, t + 1, tokens})
+			t++
+		}
+	}
+	return []FileDiff{{Hunks: []Hunk{{Edits: hl.render(tes)}}}}, nil
+}
@@ -0,0 +1,148 @@
+package highlight
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// summarize returns a compact representation of the files in a diff.
+func summarize(files []FileDiff) []string {
+	var ret []string
+	for _, f := range files {
+		ret = append(ret, fmt.Sprintf("file %s -> %s", f.OldName, f.NewName))
+		for _, h := range f.Hunks {
+			if f.HasHunkHeaders {
+				ret = append(ret, h.Header())
+			}
+			for _, ed := range h.Edits {
+				content := strings.TrimSuffix(spanRe.ReplaceAllString(string(ed.Content), ""), "\n")
+				ret = append(ret, fmt.Sprintf("%v %d %d %s", ed.Op, ed.LineNoX, ed.LineNoY, content))
+			}
+		}
+	}
+	return ret
+}
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			name: "bare",
+			in:   " a\n-b\n+c\n d\n",
+			want: []string{
+				"file  -> ",
+				"Match 1 1 a",
+				"Delete 2 -1 b",
+				"Insert -1 2 c",
+				"Match 3 3 d",
+			},
+		},
+		{
+			name: "unified",
+			in: `--- a.txt	2024-01-01 00:00:00
++++ b.txt	2024-01-02 00:00:00
+@@ -10,3 +10,3 @@ func f() {
+ a
+-b
++c
+ d
+@@ -20 +20,2 @@
+ e
++f
+`,
+			want: []string{
+				"file a.txt -> b.txt",
+				"@@ -10,3 +10,3 @@ func f() {",
+				"Match 10 10 a",
+				"Delete 11 -1 b",
+				"Insert -1 11 c",
+				"Match 12 12 d",
+				"@@ -20,1 +20,2 @@",
+				"Match 20 20 e",
+				"Insert -1 21 f",
+			},
+		},
+		{
+			name: "git_multiple_files",
+			in: `diff --git a/x.go b/x.go
+index 1234567..89abcde 100644
+--- a/x.go
++++ b/x.go
+@@ -1,2 +1,2 @@
+ package x
+-var a = 1
+\ No newline at end of file
++var a = 2
+\ No newline at end of file
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++--- not a header
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-a
+-
+`,
+			want: []string{
+				"file x.go -> x.go",
+				"@@ -1,2 +1,2 @@",
+				"Match 1 1 package x",
+				"Delete 2 -1 var a = <del>1</del>",
+				"Insert -1 2 var a = <ins>2</ins>",
+				"file /dev/null -> new.txt",
+				"@@ -0,0 +1,1 @@",
+				"Insert -1 1 --- not a header",
+				"file gone.txt -> /dev/null",
+				"@@ -1,2 +0,0 @@",
+				"Delete 1 -1 a",
+				"Delete 2 -1 ",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParseDiff(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, summarize(files)); diff != "" {
+				t.Errorf("ParseDiff() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseDiff_Lang(t *testing.T) {
+	in := "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-func f()\n+func g()\n"
+	files, err := ParseDiff(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := string(files[0].Hunks[0].Edits[0].Content); !strings.Contains(got, `<span class="hl-b">func</span>`) {
+		t.Errorf("ParseDiff() didn't highlight Go: %s", got)
+	}
+}
+
+func TestParseDiff_Errors(t *testing.T) {
+	for _, in := range []string{
+		"@@ -1,2 +1,2 @@\n a\n",
+		"@@ -1 +1 @@\n?a\n",
+		"@@ -1 +1 @@\n a\n@@ -x +1 @@\n",
+	} {
+		if _, err := ParseDiff(in); err == nil {
+			t.Errorf("ParseDiff(%q): expected error", in)
+		}
+	}
+}
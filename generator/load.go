@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io/fs"
@@ -10,19 +11,41 @@ import (
 	"path/filepath"
 	"strings"
 
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/directives"
 	"flo.znkr.io/generator/metadata"
 	"flo.znkr.io/generator/renderers"
 	"flo.znkr.io/generator/site"
 )
 
 // load loads a site from the directory dir.
-func load(dir string) (*site.Site, error) {
+//
+// If diags is not nil, load keeps going when a doc fails to load: The doc is skipped and the
+// diagnostics are added to diags. Otherwise, load stops at the first failing doc.
+func load(dir string, diags *diag.List) (*site.Site, error) {
 	templates, err := loadTemplates(filepath.Join(dir, "templates"))
 	if err != nil {
 		return nil, fmt.Errorf("loading templates: %v", err)
 	}
 
-	docs, err := loadDocs(filepath.Join(dir, "site"), templates)
+	cfg, err := loadConfig(dir)
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %v", err)
+	}
+	opts := directives.Options{
+		Commands:   cfg.Output.Commands,
+		Unisolated: cfg.Output.Unisolated,
+	}
+	opts.Resolver, err = directives.NewResolver(filepath.Join(dir, "site"), cfg.Includes.Allow...)
+	if err != nil {
+		return nil, err
+	}
+	opts.RedactRules, err = cfg.redactRules()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %v", err)
+	}
+
+	docs, err := loadDocs(filepath.Join(dir, "site"), templates, opts, diags)
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +58,7 @@ func load(dir string) (*site.Site, error) {
 				Title:    "Florian Zenker's website",
 				GoImport: "flo.znkr.io git https://github.com/znkr/flo.znkr.io",
 			},
-			Renderer: mustNewIndexRenderer(templates),
+			Renderer: mustNewIndexRenderer(templates, opts),
 		},
 		site.Doc{
 			Path:     "/feed.atom",
@@ -72,11 +95,12 @@ func loadTemplates(dir string) (*template.Template, error) {
 	return root, err
 }
 
-func loadDocs(dir string, templates *template.Template) ([]site.Doc, error) {
+func loadDocs(dir string, templates *template.Template, opts directives.Options, diags *diag.List) ([]site.Doc, error) {
 	markdownRenderers := make(map[string]*renderers.MarkdownRenderer)
 	for _, typ := range []string{"article", "page"} {
 		r, err := renderers.NewMarkdownRenderer(templates, renderers.MarkdownRendererOptions{
 			PageTemplate: typ,
+			Directives:   opts,
 		})
 		if err != nil {
 			return nil, err
@@ -116,12 +140,22 @@ func loadDocs(dir string, templates *template.Template) ([]site.Doc, error) {
 		dir, base := filepath.Split(path)
 		ext := filepath.Ext(base)
 
+		// fail reports an error for the current doc, it either skips the doc or stops loading.
+		fail := func(err error) error {
+			if diags == nil {
+				return err
+			}
+			diags.Add(fpath, err)
+			return nil
+		}
+
 		switch ext {
 		case ".md":
 			doc.Meta, doc.Data, err = metadata.Parse(data)
 			if err != nil {
-				return fmt.Errorf("parsing metadata: %v", err)
+				return fail(diag.Wrap(fpath, 1, err))
 			}
+			doc.DataLine = 1 + bytes.Count(data[:len(data)-len(doc.Data)], []byte("\n"))
 
 			if p := strings.TrimSuffix(base, ext); p == "index" {
 				if dir == "/" {
@@ -135,7 +169,7 @@ func loadDocs(dir string, templates *template.Template) ([]site.Doc, error) {
 			doc.MimeType = "text/html;charset=utf-8"
 			doc.Renderer = markdownRenderers[doc.Meta.Type]
 			if doc.Renderer == nil {
-				return fmt.Errorf("unknown doc type: %s", doc.Meta.Type)
+				return fail(diag.Errorf(fpath, 0, 0, "unknown doc type: %s", doc.Meta.Type))
 			}
 		default:
 			doc.MimeType = mime.TypeByExtension(filepath.Ext(fpath))
@@ -148,12 +182,31 @@ func loadDocs(dir string, templates *template.Template) ([]site.Doc, error) {
 	if err != nil {
 		return nil, fmt.Errorf("loading docs: %v", err)
 	}
+
+	// Redacted directives link to the files served by the site, these must be redacted too.
+	reds := make(map[string]*directives.Redactor)
+	for _, doc := range docs {
+		if r, ok := doc.Renderer.(*renderers.MarkdownRenderer); ok {
+			for f, red := range r.Redactions(&doc) {
+				reds[f] = reds[f].Join(red)
+			}
+		}
+	}
+	for i := range docs {
+		if len(reds) == 0 || docs[i].Renderer != renderers.Passthrough {
+			continue
+		}
+		if p, err := filepath.EvalSymlinks(docs[i].Source); err == nil && reds[p] != nil {
+			docs[i].Renderer = renderers.Redacted(reds[p])
+		}
+	}
 	return docs, nil
 }
 
-func mustNewIndexRenderer(templates *template.Template) *renderers.MarkdownRenderer {
+func mustNewIndexRenderer(templates *template.Template, opts directives.Options) *renderers.MarkdownRenderer {
 	r, err := renderers.NewMarkdownRenderer(templates, renderers.MarkdownRendererOptions{
 		PageTemplate: "index",
+		Directives:   opts,
 	})
 	if err != nil {
 		log.Fatalf("creating index renderer: %v", err)
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flo.znkr.io/generator/diag"
+)
+
+// newTestRoot returns a root directory with the templates of the site and the given files.
+func newTestRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.CopyFS(filepath.Join(dir, "templates"), os.DirFS("../templates")); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad_Redacted(t *testing.T) {
+	dir := newTestRoot(t, map[string]string{
+		"site/post/index.md": "# Post\n:published: 2025-01-01\n\n" +
+			"<!--#include-snippet file=\"deploy.sh\" redact=\"TOKEN=(\\S+)\" -->\n",
+		"site/post/deploy.sh": "TOKEN=hunter2 ./deploy\n",
+		"site/post/other.sh":  "TOKEN=hunter2 ./other\n",
+	})
+
+	var diags diag.List
+	s, err := load(dir, &diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := diags.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.RenderPage(s.Doc("/post"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "hunter2") {
+		t.Errorf("page reveals redacted token:\n%s", page)
+	}
+	if !strings.Contains(string(page), `href="/post/deploy.sh"`) {
+		t.Errorf("page doesn't link to the redacted copy of deploy.sh:\n%s", page)
+	}
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/post/deploy.sh", "TOKEN=REDACTED ./deploy\n"},
+		{"/post/other.sh", "TOKEN=hunter2 ./other\n"},
+	}
+	for _, tt := range tests {
+		got, err := s.RenderPage(s.Doc(tt.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("RenderPage(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoad_RedactedOutsideOfSite(t *testing.T) {
+	dir := newTestRoot(t, map[string]string{
+		"config.json": `{"includes": {"allow": ["examples"]}}`,
+		"site/post/index.md": "# Post\n:published: 2025-01-01\n\n" +
+			"<!--#include-snippet file=\"../../examples/deploy.sh\" redact=\"TOKEN=(\\S+)\" -->\n",
+		"examples/deploy.sh": "TOKEN=hunter2 ./deploy\n",
+	})
+
+	var diags diag.List
+	s, err := load(dir, &diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.RenderPage(s.Doc("/post"))
+	if err == nil || !strings.Contains(err.Error(), "redaction is only supported for files served by the site") {
+		t.Errorf("RenderPage() = %v, want error for redacting a file outside of the site", err)
+	}
+}
@@ -18,6 +18,7 @@ func main() {
 
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(packCmd)
+	rootCmd.AddCommand(checkCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -2,11 +2,11 @@ package metadata
 
 import (
 	"cmp"
-	"fmt"
 	"slices"
 	"strings"
 	"time"
 
+	"flo.znkr.io/generator/diag"
 	"flo.znkr.io/generator/site"
 )
 
@@ -17,9 +17,10 @@ import (
 //	:<key>: <value>
 //
 // It returns the parsed [site.Metadata] and the remaining input data (i.e. everything after the
-// metadata header).
+// metadata header). Errors are reported as [diag.Diagnostic] with positions relative to in.
 func Parse(in []byte) (*site.Metadata, []byte, error) {
 	meta := site.Metadata{}
+	lineno := 1
 
 	// Take title from first header. This assumes that every document starts with the header
 	// and doesn't have anything before it.
@@ -30,11 +31,14 @@ func Parse(in []byte) (*site.Metadata, []byte, error) {
 		}
 		meta.Title = strings.TrimSpace(string(in[1:eol]))
 		in = in[eol+1:]
+		lineno++
 	}
 
 	// Parse metadata lines. These lines follow a simple format:
 	//   :<key>: <value>
 	metadir := make(map[string]string)
+	metaline := make(map[string]int)
+	metacol := make(map[string]int) // column of the first character of the value
 	for len(in) > 0 && in[0] == ':' {
 		pos := 1
 		end := pos + slices.Index(in[pos:], ':')
@@ -43,6 +47,12 @@ func Parse(in []byte) (*site.Metadata, []byte, error) {
 		}
 
 		key := string(in[pos:end])
+		metaline[key] = lineno
+		first := in[end+1:]
+		if eol := slices.Index(first, '\n'); eol >= 0 {
+			first = first[:eol]
+		}
+		metacol[key] = end + 2 + len(first) - len(strings.TrimLeft(string(first), " \t"))
 
 		var val strings.Builder
 		for {
@@ -58,6 +68,7 @@ func Parse(in []byte) (*site.Metadata, []byte, error) {
 			if in[end-1] == '\\' {
 				val.Write(in[pos : end-1])
 				val.WriteByte('\n')
+				lineno++
 			} else {
 				val.Write(in[pos:end])
 				break
@@ -65,6 +76,7 @@ func Parse(in []byte) (*site.Metadata, []byte, error) {
 		}
 		if end < len(in) {
 			in = in[end+1:]
+			lineno++
 		} else {
 			in = nil
 		}
@@ -84,7 +96,7 @@ func Parse(in []byte) (*site.Metadata, []byte, error) {
 		}
 		t, err := time.ParseInLocation("2006-01-02", v, tz)
 		if err != nil {
-			return time.Time{}, fmt.Errorf("parsing %s: %v", key, err)
+			return time.Time{}, diag.Errorf("", metaline[key], metacol[key], "parsing %s: %v", key, err)
 		}
 		return t, nil
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"flo.znkr.io/generator/diag"
 	"flo.znkr.io/generator/site"
 	"github.com/google/go-cmp/cmp"
 )
@@ -197,24 +198,53 @@ func TestParse(t *testing.T) {
 
 func TestParse_Errors(t *testing.T) {
 	tests := []struct {
-		name    string
-		in      string
-		wantErr string
+		name     string
+		in       string
+		wantErr  string
+		wantLine int
+		wantCol  int
 	}{
 		{
-			name:    "invalid_published_date",
-			in:      "# Title\n:published: not-a-date\n",
-			wantErr: "parsing published",
+			name:     "invalid_published_date",
+			in:       "# Title\n:published: not-a-date\n",
+			wantErr:  "parsing published",
+			wantLine: 2,
+			wantCol:  13,
 		},
 		{
-			name:    "invalid_updated_date",
-			in:      "# Title\n:updated: 2024-99-99\n",
-			wantErr: "parsing updated",
+			name:     "invalid_updated_date",
+			in:       "# Title\n:updated: 2024-99-99\n",
+			wantErr:  "parsing updated",
+			wantLine: 2,
+			wantCol:  11,
 		},
 		{
-			name:    "invalid_date_format",
-			in:      "# Title\n:published: 12/25/2024\n",
-			wantErr: "parsing published",
+			name:     "invalid_date_format",
+			in:       "# Title\n:published: 12/25/2024\n",
+			wantErr:  "parsing published",
+			wantLine: 2,
+			wantCol:  13,
+		},
+		{
+			name:     "after_multiline_value",
+			in:       "# Title\n:summary: one\\\ntwo\n:published: 12/25/2024\n",
+			wantErr:  "parsing published",
+			wantLine: 4,
+			wantCol:  13,
+		},
+		{
+			name:     "extra_spaces",
+			in:       "# Title\n:published:   12/25/2024\n",
+			wantErr:  "parsing published",
+			wantLine: 2,
+			wantCol:  15,
+		},
+		{
+			name:     "without_title",
+			in:       ":published: 12/25/2024\n",
+			wantErr:  "parsing published",
+			wantLine: 1,
+			wantCol:  13,
 		},
 	}
 
@@ -227,6 +257,13 @@ func TestParse_Errors(t *testing.T) {
 			if !strings.Contains(err.Error(), tt.wantErr) {
 				t.Errorf("expected error containing %q, got %q", tt.wantErr, err.Error())
 			}
+			d, ok := err.(*diag.Diagnostic)
+			if !ok {
+				t.Fatalf("expected *diag.Diagnostic, got %T", err)
+			}
+			if d.Line != tt.wantLine || d.Col != tt.wantCol {
+				t.Errorf("expected error at %d:%d, got %d:%d", tt.wantLine, tt.wantCol, d.Line, d.Col)
+			}
 		})
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"flo.znkr.io/generator/diag"
 	"flo.znkr.io/generator/pack"
 	"github.com/spf13/cobra"
 )
@@ -17,10 +18,37 @@ var packCmd = &cobra.Command{
 		if err != nil {
 			return fmt.Errorf("determining workdir: %v", err)
 		}
-		s, err := load(dir)
+		cfg, err := loadConfig(dir)
+		if err != nil {
+			return fmt.Errorf("loading config: %v", err)
+		}
+
+		keepGoing, _ := cmd.Flags().GetBool("keep-going")
+		if !keepGoing {
+			s, err := load(dir, nil)
+			if err != nil {
+				return fmt.Errorf("loading site: %v", err)
+			}
+			return pack.Pack(args[0], s, pack.Options{Secrets: cfg.secretScanner()})
+		}
+
+		var diags diag.List
+		s, err := load(dir, &diags)
 		if err != nil {
 			return fmt.Errorf("loading site: %v", err)
 		}
-		return pack.Pack(args[0], s)
+		diags.Add("", pack.Pack(args[0], s, pack.Options{KeepGoing: true, Secrets: cfg.secretScanner()}))
+		for _, d := range diags {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		if diags.Err() != nil {
+			os.Remove(args[0]) // don't leave a partial site behind
+			return fmt.Errorf("packing failed with %d diagnostics", len(diags))
+		}
+		return nil
 	},
 }
+
+func init() {
+	packCmd.Flags().BoolP("keep-going", "k", false, "report all diagnostics instead of stopping at the first error")
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
 
 	"github.com/tdewolff/minify/v2"
 	"github.com/tdewolff/minify/v2/css"
@@ -15,10 +14,29 @@ import (
 	"github.com/tdewolff/minify/v2/svg"
 	"github.com/tdewolff/minify/v2/xml"
 
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/secrets"
 	"flo.znkr.io/generator/site"
 )
 
-func Pack(filename string, s *site.Site) error {
+// MimeTypeRecord is the PAX record of a file in the archive that holds the mime type of the doc.
+// It is stored as the user.mime_type extended attribute, which tar tools know about.
+const MimeTypeRecord = "SCHILY.xattr.user.mime_type"
+
+// Options configure how a site is packed.
+type Options struct {
+	// KeepGoing makes Pack continue when a doc fails to render. All errors are then returned as a
+	// [diag.List] after all docs have been processed.
+	KeepGoing bool
+
+	// Secrets scans the output of all docs for secrets, if it's not nil. Docs containing
+	// secrets fail to pack.
+	Secrets *secrets.Scanner
+}
+
+// Pack renders all docs of s and writes them into the tar file filename. The file is removed if
+// packing fails.
+func Pack(filename string, s *site.Site, opts Options) (err error) {
 	minifier := minify.New()
 	minifier.AddFunc("text/css", css.Minify)
 	minifier.AddFunc("image/svg+xml", svg.Minify)
@@ -29,19 +47,42 @@ func Pack(filename string, s *site.Site) error {
 	if err != nil {
 		return fmt.Errorf("opening file: %v", err)
 	}
-	defer file.Close()
-
 	tw := tar.NewWriter(file)
-	defer tw.Close()
+	defer func() {
+		if cerr := tw.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("writing archive: %v", cerr)
+		}
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("closing file: %v", cerr)
+		}
+		if err != nil {
+			os.Remove(filename)
+		}
+	}()
 
 	dirs := make(map[string]bool)
 
+	var diags diag.List
 	for _, d := range s.AllDocs() {
 		b, err := s.RenderPage(d)
 		if err != nil {
+			if opts.KeepGoing {
+				diags.Add(d.Source, err)
+				continue
+			}
 			return err
 		}
 
+		if opts.Secrets != nil {
+			if err := opts.Secrets.Scan(d, b); err != nil {
+				if opts.KeepGoing {
+					diags.Add(d.Source, err)
+					continue
+				}
+				return err
+			}
+		}
+
 		mime, _, err := mime.ParseMediaType(d.MimeType)
 		if err != nil {
 			return fmt.Errorf("invalid mime type for file %v: %v", d.Path, err)
@@ -55,13 +96,7 @@ func Pack(filename string, s *site.Site) error {
 			}
 		}
 
-		path := d.Path
-		if path == "/" {
-			path = "index.html"
-		} else if mime == "text/html" && filepath.Ext(path) == "" {
-			path += "/index.html"
-		}
-		path = strings.TrimPrefix(path, "/")
+		path := d.Filename()
 
 		if dir := filepath.Dir(path); !dirs[dir] {
 			name := "./" + dir + "/"
@@ -79,9 +114,10 @@ func Pack(filename string, s *site.Site) error {
 		}
 
 		hdr := &tar.Header{
-			Name: "./" + path,
-			Mode: int64(0644),
-			Size: int64(len(b)),
+			Name:       "./" + path,
+			Mode:       int64(0644),
+			Size:       int64(len(b)),
+			PAXRecords: map[string]string{MimeTypeRecord: d.MimeType},
 		}
 		if err := tw.WriteHeader(hdr); err != nil {
 			return fmt.Errorf("writing header: %v", err)
@@ -91,5 +127,5 @@ func Pack(filename string, s *site.Site) error {
 		}
 	}
 
-	return nil
+	return diags.Err()
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 
+	"flo.znkr.io/generator/diag"
 	"flo.znkr.io/generator/directives"
 	"flo.znkr.io/generator/goldmark"
 	"flo.znkr.io/generator/site"
@@ -17,6 +18,7 @@ type MarkdownRenderer struct {
 
 type MarkdownRendererOptions struct {
 	PageTemplate string
+	Directives   directives.Options
 }
 
 func NewMarkdownRenderer(templates *template.Template, opts MarkdownRendererOptions) (*MarkdownRenderer, error) {
@@ -27,7 +29,7 @@ func NewMarkdownRenderer(templates *template.Template, opts MarkdownRendererOpti
 
 	return &MarkdownRenderer{
 		page:       page,
-		directives: directives.NewRenderer(templates),
+		directives: directives.NewRenderer(templates, opts.Directives),
 	}, nil
 }
 
@@ -55,20 +57,32 @@ func (r *MarkdownRenderer) RenderPage(s *site.Site, doc *site.Doc) ([]byte, erro
 		TOC:     template.HTML(toc),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("rendering template: %v", err)
+		return nil, diag.Errorf(doc.Source, 0, 0, "rendering template %s: %v", r.page.Name(), err)
 	}
 	return buf.Bytes(), nil
 }
 
+// renderContent renders the markdown content of doc. Errors are reported as [diag.Diagnostic] with
+// positions in the source file of doc.
 func (r *MarkdownRenderer) renderContent(doc *site.Doc) (content []byte, toc []byte, err error) {
-	content, toc, err = goldmark.Render(doc.Data)
+	content, toc, err = goldmark.Render(doc.Data, func(buf *bytes.Buffer, dir *directives.Directive) error {
+		return r.directives.Render(buf, doc, dir)
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, diag.Wrap(doc.Source, doc.DataLine, err)
 	}
+	return content, toc, nil
+}
 
-	content, err = r.directives.Render(doc, content)
-	if err != nil {
-		return nil, nil, err
+// Redactions returns the redactors for all files redacted by directives in doc, keyed by the
+// resolved path of the file.
+func (r *MarkdownRenderer) Redactions(doc *site.Doc) map[string]*directives.Redactor {
+	reds := make(map[string]*directives.Redactor)
+	for _, dir := range goldmark.Directives(doc.Data) {
+		files, red := r.directives.Redactions(doc, dir)
+		for _, f := range files {
+			reds[f] = reds[f].Join(red)
+		}
 	}
-	return content, toc, nil
+	return reds
 }
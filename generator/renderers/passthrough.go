@@ -1,6 +1,9 @@
 package renderers
 
-import "flo.znkr.io/generator/site"
+import (
+	"flo.znkr.io/generator/directives"
+	"flo.znkr.io/generator/site"
+)
 
 var Passthrough site.Renderer = &passthroughRenderer{}
 
@@ -13,3 +16,20 @@ func (r *passthroughRenderer) RenderContent(_ *site.Site, doc *site.Doc) ([]byte
 func (r *passthroughRenderer) RenderPage(_ *site.Site, doc *site.Doc) ([]byte, error) {
 	return doc.Data, nil
 }
+
+// Redacted returns a renderer that passes docs through with all secrets redacted by red.
+func Redacted(red *directives.Redactor) site.Renderer {
+	return &redactedRenderer{red}
+}
+
+type redactedRenderer struct {
+	red *directives.Redactor
+}
+
+func (r *redactedRenderer) RenderContent(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return r.red.Redact(doc.Data), nil
+}
+
+func (r *redactedRenderer) RenderPage(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return r.red.Redact(doc.Data), nil
+}
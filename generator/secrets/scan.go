@@ -0,0 +1,207 @@
+// Package secrets finds secrets, like API tokens and private keys, in the output of the site.
+package secrets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/site"
+)
+
+// Allow allowlists the finding with the hash Hash, see [Hash]. If File isn't empty, the finding is
+// only allowed in the file File, the name of a doc in the packed site. The hash identifies a single
+// secret independently of where it is in the output, it's part of every diagnostic. Other
+// findings, e.g. a real token added to the same line later, are still reported.
+type Allow struct {
+	File string
+	Hash string
+}
+
+// Hash returns the hash of the string s found by the scanner, it doesn't reveal s.
+func Hash(s []byte) string {
+	sum := sha256.Sum256(s)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Scanner scans the output of docs for secrets.
+type Scanner struct {
+	allow []Allow
+}
+
+// NewScanner returns a scanner that ignores all findings allowed by allow.
+func NewScanner(allow ...Allow) *Scanner {
+	return &Scanner{allow: allow}
+}
+
+// Scan scans data, the output of doc, for secrets. Every finding that isn't allowlisted is
+// reported as [diag.Diagnostic] in the file name of doc, positions are relative to data. Invalid
+// UTF-8 sequences are replaced before scanning, so that they can't hide secrets.
+func (s *Scanner) Scan(doc *site.Doc, data []byte) error {
+	data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+	name := doc.Filename()
+	var diags diag.List
+	start, blockEnd := 0, 0 // offsets in data of the current line and the end of the last block
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		lineno := i + 1
+		for _, f := range scanLine(line) {
+			if start+f.off < blockEnd {
+				continue // part of a block that's already reported
+			}
+			secret := line[f.off:f.end]
+			if f.block != nil {
+				rest := data[start+f.off:]
+				if m := f.block.FindIndex(rest); m != nil {
+					rest = rest[:m[1]]
+				}
+				secret, blockEnd = rest, start+f.off+len(rest)
+			}
+			hash := Hash(secret)
+			if s.allowed(name, hash) {
+				continue
+			}
+			col := 1 + utf8.RuneCount(line[:f.off])
+			diags.Add(name, diag.Errorf(name, lineno, col, "%s [hash %s]", f.msg, hash))
+		}
+		start += len(line) + 1
+	}
+	return diags.Err()
+}
+
+func (s *Scanner) allowed(name, hash string) bool {
+	return slices.ContainsFunc(s.allow, func(a Allow) bool {
+		return a.Hash == hash && (a.File == "" || a.File == name)
+	})
+}
+
+// finding is a possible secret at offsets [off, end) in a line.
+type finding struct {
+	off, end int
+	msg      string
+	block    *regexp.Regexp // see the field of tokens
+}
+
+// tokens are the formats of known secrets.
+var tokens = []struct {
+	name  string
+	re    *regexp.Regexp
+	mask  bool           // the match is the secret itself, it must not be revealed
+	block *regexp.Regexp // if not nil, the secret is a block that ends with the first match
+}{
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`), true, nil},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`), true, nil},
+	{"PEM private key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`), false, regexp.MustCompile(`-----END [A-Z0-9 ]*-----`)},
+}
+
+// Candidates for high-entropy strings are runs of at least 20 base64 or hex characters. In URLs,
+// every path segment is a candidate on its own.
+var (
+	candidateRe = regexp.MustCompile(`[A-Za-z0-9+/_-]{20,}={0,2}`)
+	segmentRe   = regexp.MustCompile(`[A-Za-z0-9+_-]{20,}={0,2}`)
+	urlRe       = regexp.MustCompile(`https?://[^\s"'<>()]+`)
+	hexRe       = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// The entropy of a string of n characters from an alphabet of k characters is at most
+// log2(min(n, k)) bits per character. Random strings come close to it, the entropy of 99% of random
+// base64 strings of 20 to 64 characters is above 0.82 of the maximum, for hex strings it's above
+// 0.72. Words and identifiers reach these values too, a base64 candidate must mix upper and lower
+// case letters and digits to be considered random.
+const (
+	base64Entropy = 0.82
+	hexEntropy    = 0.72
+)
+
+// scanLine returns the findings in line. Known secrets are preferred over high-entropy strings.
+func scanLine(line []byte) []finding {
+	var ret []finding
+	var known [][]int
+	for _, tok := range tokens {
+		for _, m := range tok.re.FindAllIndex(line, -1) {
+			msg := "possible secret: " + tok.name
+			if tok.mask {
+				msg += " " + mask(line[m[0]:m[1]])
+			}
+			ret = append(ret, finding{m[0], m[1], msg, tok.block})
+			known = append(known, m)
+		}
+	}
+	overlaps := func(spans [][]int, m []int) bool {
+		return slices.ContainsFunc(spans, func(s []int) bool { return s[0] < m[1] && m[0] < s[1] })
+	}
+	urls := urlRe.FindAllIndex(line, -1)
+	var cands [][]int
+	for _, m := range candidateRe.FindAllIndex(line, -1) {
+		if !overlaps(urls, m) {
+			cands = append(cands, m)
+		}
+	}
+	for _, u := range urls {
+		for _, m := range segmentRe.FindAllIndex(line[u[0]:u[1]], -1) {
+			m := []int{u[0] + m[0], u[0] + m[1]}
+			s := line[m[0]:m[1]]
+			if hexRe.Match(s) && (len(s) == 40 || len(s) == 64) {
+				continue // commit hashes, e.g. in links to a repository
+			}
+			cands = append(cands, m)
+		}
+	}
+	for _, m := range cands {
+		if overlaps(known, m) {
+			continue
+		}
+		s := line[m[0]:m[1]]
+		if h, ok := highEntropy(s); ok {
+			ret = append(ret, finding{m[0], m[1], fmt.Sprintf("possible secret: high-entropy string %s (%.1f bits per character)", mask(s), h), nil})
+		}
+	}
+	slices.SortFunc(ret, func(a, b finding) int { return a.off - b.off })
+	return ret
+}
+
+// highEntropy returns the entropy of the candidate s and whether it's high enough for s to be a
+// random string.
+func highEntropy(s []byte) (float64, bool) {
+	h := entropy(bytes.TrimRight(s, "="))
+	n := float64(len(s))
+	if hexRe.Match(s) {
+		return h, h > hexEntropy*math.Log2(min(n, 16)) && bytes.ContainsAny(s, "abcdefABCDEF") && bytes.ContainsAny(s, "0123456789")
+	}
+	mixed := bytes.ContainsFunc(s, isUpper) && bytes.ContainsFunc(s, isLower) && bytes.ContainsFunc(s, isDigit)
+	return h, mixed && h > base64Entropy*math.Log2(min(n, 64))
+}
+
+func isUpper(r rune) bool { return 'A' <= r && r <= 'Z' }
+func isLower(r rune) bool { return 'a' <= r && r <= 'z' }
+func isDigit(r rune) bool { return '0' <= r && r <= '9' }
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s []byte) float64 {
+	var freq [256]int
+	for _, c := range s {
+		freq[c]++
+	}
+	var h float64
+	for _, n := range freq {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// mask returns a quoted prefix of the secret s, so that diagnostics don't reveal it.
+func mask(s []byte) string {
+	const n = 4
+	if len(s) <= n {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%q", string(s[:n])+"…")
+}
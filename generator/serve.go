@@ -20,18 +20,25 @@ var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Serve site without actually generating any file",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if from, _ := cmd.Flags().GetString("from"); from != "" || embeddedSite != nil {
+			return serveArchive(cmd, from)
+		}
+
 		dir, err := os.Getwd()
 		if err != nil {
 			return fmt.Errorf("determining workdir: %v", err)
 		}
-		s, err := load(dir)
+		s, err := load(dir, nil)
 		if err != nil {
 			return fmt.Errorf("loading site: %v", err)
 		}
 
 		// Start serving.
-		const addr = "localhost:8080"
-		server, err := server.Run(addr, s)
+		addr, _ := cmd.Flags().GetString("addr")
+		cacheControl, _ := cmd.Flags().GetString("cache-control")
+		server, err := server.Run(addr, s, server.Options{
+			CacheControl: cacheControl,
+		})
 		if err != nil {
 			return err
 		}
@@ -49,6 +56,21 @@ var serveCmd = &cobra.Command{
 				return fmt.Errorf("starting watch: %v", err)
 			}
 		}
+		// Watch the root directory for changes to the config file.
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("starting watch: %v", err)
+		}
+		// Directives can include files from git revisions, watch the refs too. The site might
+		// not be in a git repository.
+		gitDir := filepath.Join(dir, ".git")
+		if _, err := os.Stat(filepath.Join(gitDir, "refs")); err == nil {
+			if err := watcher.Add(gitDir); err != nil {
+				return fmt.Errorf("starting watch: %v", err)
+			}
+			if err := watchDir(watcher, filepath.Join(gitDir, "refs")); err != nil {
+				return fmt.Errorf("starting watch: %v", err)
+			}
+		}
 
 		// Setup signals to react to Ctrl-C.
 		sigint := make(chan os.Signal, 1)
@@ -62,6 +84,16 @@ var serveCmd = &cobra.Command{
 					continue
 				}
 
+				// Only the config file matters in the root directory.
+				if filepath.Dir(event.Name) == dir && filepath.Base(event.Name) != configFile {
+					continue
+				}
+
+				// Git changes lots of files in its directory, only changed refs matter.
+				if rel, err := filepath.Rel(gitDir, event.Name); err == nil && !strings.HasPrefix(rel, "..") && !isGitRef(rel) {
+					continue
+				}
+
 				// Update watch list should new directories be added or removed.
 				if stat, err := os.Stat(event.Name); err == nil && event.Has(fsnotify.Create) && stat.IsDir() {
 					if err := watchDir(watcher, event.Name); err != nil {
@@ -74,7 +106,7 @@ var serveCmd = &cobra.Command{
 				// Reload site. This is more than fast enough for now, so now caching or anything
 				// is necessary here.
 				start := time.Now()
-				s, err := load(dir)
+				s, err := load(dir, nil)
 				if err != nil {
 					log.Printf("failed to update site: %v", err)
 					continue
@@ -98,6 +130,12 @@ var serveCmd = &cobra.Command{
 	},
 }
 
+func init() {
+	serveCmd.Flags().String("addr", "localhost:8080", "address to listen on")
+	serveCmd.Flags().String("cache-control", "no-cache", "value of the Cache-Control header sent with every response")
+	serveCmd.Flags().String("from", "", "serve a packed site from this archive instead of the working directory; defaults to the embedded archive if there is one")
+}
+
 func watchDir(watcher *fsnotify.Watcher, dir string) error {
 	walkfn := func(path string, d fs.DirEntry, err error) error {
 		if d.IsDir() {
@@ -116,3 +154,12 @@ func watchDir(watcher *fsnotify.Watcher, dir string) error {
 	}
 	return nil
 }
+
+// isGitRef reports whether name, relative to the git directory, is a file that stores refs, e.g.
+// a branch, tag, or remote-tracking branch.
+func isGitRef(name string) bool {
+	if strings.HasSuffix(name, ".lock") {
+		return false
+	}
+	return name == "HEAD" || name == "packed-refs" || strings.HasPrefix(name, "refs"+string(filepath.Separator))
+}
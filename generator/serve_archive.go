@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"flo.znkr.io/generator/server"
+	"flo.znkr.io/generator/site"
+	"github.com/spf13/cobra"
+)
+
+// serveArchive serves a packed site from the archive file from, or from the embedded archive if
+// from is empty.
+//
+// The archive is reloaded on SIGHUP. SIGINT and SIGTERM trigger a graceful shutdown.
+func serveArchive(cmd *cobra.Command, from string) error {
+	loadSite := func() (*site.Site, error) {
+		if from == "" {
+			return server.LoadArchive(bytes.NewReader(embeddedSite))
+		}
+		f, err := os.Open(from)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return server.LoadArchive(f)
+	}
+
+	s, err := loadSite()
+	if err != nil {
+		return fmt.Errorf("loading archive: %v", err)
+	}
+
+	addr, _ := cmd.Flags().GetString("addr")
+	cacheControl, _ := cmd.Flags().GetString("cache-control")
+	server, err := server.Run(addr, s, server.Options{
+		CacheControl: cacheControl,
+	})
+	if err != nil {
+		return err
+	}
+	if from == "" {
+		log.Printf("Now serving embedded site at %s", addr)
+	} else {
+		log.Printf("Now serving %s at %s", from, addr)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		select {
+		case sig := <-sigc:
+			if sig == syscall.SIGHUP {
+				if from == "" {
+					log.Printf("Received SIGHUP, ignoring it for embedded site")
+					continue
+				}
+				s, err := loadSite()
+				if err != nil {
+					log.Printf("failed to reload archive: %v", err)
+					continue
+				}
+				server.ReplaceSite(s)
+				log.Printf("Archive reloaded")
+				continue
+			}
+
+			log.Printf("Received %v, shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return server.Shutdown(ctx)
+
+		case err := <-server.Error():
+			return fmt.Errorf("serving: %v", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"flo.znkr.io/generator/pack"
+	"flo.znkr.io/generator/site"
+)
+
+// headersFile is the name of the optional headers manifest in an archive.
+//
+// The manifest lists additional headers to send for docs. Every unindented line starts a new rule
+// with a doc path, where a trailing * matches any path with the same prefix. Every following
+// indented line adds a header to the rule:
+//
+//	/_assets/*
+//	  Cache-Control: public, max-age=86400
+//	/feed.atom
+//	  Access-Control-Allow-Origin: *
+const headersFile = "_headers"
+
+// LoadArchive loads a site from a tar archive as produced by [pack.Pack].
+//
+// The mime type of a doc is taken from the archive, if it's missing it's derived from the file
+// extension or, as a last resort, detected from the content. All docs of the site serve the exact
+// bytes found in the archive. A file with a .gz extension is used as the precompressed gzip
+// encoding of the file with the same name but without the extension if such a file exists.
+// Additional headers are read from the manifest in the _headers file.
+func LoadArchive(r io.Reader) (*site.Site, error) {
+	files := make(map[string][]byte)
+	mimeTypes := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", hdr.Name, err)
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		files[name] = b
+		if mt, ok := hdr.PAXRecords[pack.MimeTypeRecord]; ok {
+			mimeTypes[name] = mt
+		}
+	}
+
+	ar := &archiveRenderer{
+		gzip: make(map[string][]byte),
+	}
+	if b, ok := files[headersFile]; ok {
+		rules, err := parseHeaders(b)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %v", headersFile, err)
+		}
+		ar.headers = rules
+		delete(files, headersFile)
+	}
+
+	var docs []site.Doc
+	for name, b := range files {
+		if base, ok := strings.CutSuffix(name, ".gz"); ok {
+			if _, exists := files[base]; exists {
+				continue
+			}
+		}
+
+		doc := site.Doc{
+			Source:   name,
+			MimeType: cmp.Or(mimeTypes[name], mime.TypeByExtension(path.Ext(name))),
+			Data:     b,
+			Renderer: ar,
+		}
+		if doc.MimeType == "" {
+			doc.MimeType = http.DetectContentType(b)
+		}
+		switch {
+		case name == "index.html":
+			doc.Path = "/"
+		case path.Base(name) == "index.html":
+			doc.Path = "/" + path.Dir(name)
+		default:
+			doc.Path = "/" + name
+		}
+		if gz, ok := files[name+".gz"]; ok {
+			ar.gzip[doc.Path] = gz
+		}
+		docs = append(docs, doc)
+	}
+
+	return site.New(docs)
+}
+
+// archiveRenderer renders docs loaded from an archive.
+type archiveRenderer struct {
+	gzip    map[string][]byte // by doc path
+	headers []headerRule
+}
+
+type headerRule struct {
+	pattern string
+	header  [][2]string
+}
+
+func (r *archiveRenderer) RenderContent(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return doc.Data, nil
+}
+
+func (r *archiveRenderer) RenderPage(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return doc.Data, nil
+}
+
+// header returns the additional headers for doc from the headers manifest.
+func (r *archiveRenderer) header(doc *site.Doc) [][2]string {
+	var ret [][2]string
+	for _, rule := range r.headers {
+		if prefix, ok := strings.CutSuffix(rule.pattern, "*"); ok {
+			if !strings.HasPrefix(doc.Path, prefix) {
+				continue
+			}
+		} else if rule.pattern != doc.Path {
+			continue
+		}
+		ret = append(ret, rule.header...)
+	}
+	return ret
+}
+
+func parseHeaders(in []byte) ([]headerRule, error) {
+	var rules []headerRule
+	sc := bufio.NewScanner(bytes.NewReader(in))
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			// skip empty lines and comments
+		case trimmed == line:
+			if !strings.HasPrefix(line, "/") {
+				return nil, fmt.Errorf("line %d: path must start with '/'", lineno)
+			}
+			rules = append(rules, headerRule{pattern: line})
+		default:
+			if len(rules) == 0 {
+				return nil, fmt.Errorf("line %d: header without path", lineno)
+			}
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected 'Name: value'", lineno)
+			}
+			rule := &rules[len(rules)-1]
+			rule.header = append(rule.header, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+		}
+	}
+	return rules, sc.Err()
+}
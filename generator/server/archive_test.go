@@ -0,0 +1,96 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"flo.znkr.io/generator/pack"
+)
+
+func TestLoadArchive(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range []struct{ name, content, mimeType string }{
+		{"./", "", ""},
+		{"./index.html", "root", ""},
+		{"./diff/", "", ""},
+		{"./diff/index.html", "diff", ""},
+		{"./diff/index.html.gz", "not really gzip", ""},
+		{"./_assets/style.css", "css", ""},
+		{"./download.tar.gz", "archive", ""},
+		{"./_headers", "# comment\n/_assets/*\n  Cache-Control: max-age=60\n/diff\n  X-Test: yes\n", ""},
+		{"./feed.atom", "<feed/>", "application/atom+xml;charset=utf-8"},
+		{"./data.unknownext", "plain text", ""},
+	} {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content))}
+		if f.mimeType != "" {
+			hdr.PAXRecords = map[string]string{pack.MimeTypeRecord: f.mimeType}
+		}
+		if f.content == "" {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(f.content))
+	}
+	tw.Close()
+
+	s, err := LoadArchive(&buf)
+	if err != nil {
+		t.Fatalf("LoadArchive: %v", err)
+	}
+	h := newHandler(Options{})
+	h.setSite(s)
+
+	tests := []struct {
+		path, acceptEncoding string
+		wantStatus           int
+		wantBody             string
+		wantHeader           map[string]string
+	}{
+		{path: "/", wantStatus: http.StatusOK, wantBody: "root"},
+		{path: "/diff/", wantStatus: http.StatusOK, wantBody: "diff", wantHeader: map[string]string{"X-Test": "yes"}},
+		{path: "/diff/", acceptEncoding: "gzip", wantStatus: http.StatusOK, wantBody: "not really gzip", wantHeader: map[string]string{"Content-Encoding": "gzip"}},
+		{path: "/diff/index.html.gz", wantStatus: http.StatusNotFound},
+		{path: "/_assets/style.css", wantStatus: http.StatusOK, wantBody: "css", wantHeader: map[string]string{"Cache-Control": "max-age=60"}},
+		{path: "/download.tar.gz", wantStatus: http.StatusOK, wantBody: "archive"},
+		{path: "/_headers", wantStatus: http.StatusNotFound},
+		{path: "/feed.atom", wantStatus: http.StatusOK, wantBody: "<feed/>", wantHeader: map[string]string{"Content-Type": "application/atom+xml;charset=utf-8"}},
+		{path: "/data.unknownext", wantStatus: http.StatusOK, wantBody: "plain text", wantHeader: map[string]string{"Content-Type": "text/plain; charset=utf-8"}},
+	}
+	for _, tt := range tests {
+		resp := serve(h, http.MethodGet, tt.path, map[string]string{"Accept-Encoding": tt.acceptEncoding})
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if got := string(b); got != tt.wantBody {
+			t.Errorf("GET %s: body = %q, want %q", tt.path, got, tt.wantBody)
+		}
+		for k, want := range tt.wantHeader {
+			if got := resp.Header.Get(k); got != want {
+				t.Errorf("GET %s: header %s = %q, want %q", tt.path, k, got, want)
+			}
+		}
+	}
+}
+
+func TestParseHeaders_Errors(t *testing.T) {
+	for _, in := range []string{
+		"  X-Test: yes\n",
+		"no-slash\n",
+		"/path\n  missing colon\n",
+	} {
+		if _, err := parseHeaders([]byte(in)); err == nil {
+			t.Errorf("parseHeaders(%q) succeeded, want error", in)
+		}
+	}
+}
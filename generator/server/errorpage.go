@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"os"
+	"strings"
+
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/highlight"
+)
+
+// excerptContext is the number of lines shown before and after the line of an error.
+const excerptContext = 5
+
+var errorPage = template.Must(template.New("").Parse(`
+{{- define "overlay" -}}
+<style>
+#dev-server-error {
+	position: relative;
+	z-index: 1000;
+	margin: 0;
+	padding: 1em 1.5em;
+	font-family: monospace;
+	background: #fff4f4;
+	border-bottom: 3px solid #d33;
+	color: #222;
+}
+#dev-server-error h1 { margin: 0 0 0.5em 0; font-size: 1.2em; color: #d33; }
+#dev-server-error pre { white-space: pre-wrap; margin: 0 0 1em 0; }
+#dev-server-error table { border-collapse: collapse; margin: 0 0 1em 0; }
+#dev-server-error td { padding: 0 0.5em; white-space: pre; }
+#dev-server-error .line-no { color: #888; text-align: right; user-select: none; }
+#dev-server-error tr.error { background: #fadede; }
+#dev-server-error .col { color: #d33; font-weight: bold; }
+#dev-server-error button { float: right; cursor: pointer; }
+</style>
+<div id="dev-server-error">
+	{{- if .Stale }}
+	<button onclick="this.parentElement.remove()" title="Dismiss">✕</button>
+	{{- end }}
+	<h1>Failed to render {{ .Path }}</h1>
+	{{- if .File }}
+	<p>{{ .File }}:{{ .Line }}{{ if .Col }}:{{ .Col }}{{ end }}</p>
+	{{- end }}
+	<pre>{{ .Err }}</pre>
+	{{- if .Excerpt }}
+	<table>
+		{{- range .Excerpt }}
+		<tr{{ if eq .LineNo $.Line }} class="error"{{ end }}>
+			<td class="line-no">{{ .LineNo }}</td>
+			<td><code>{{ .Content }}</code></td>
+		</tr>
+		{{- if and (eq .LineNo $.Line) $.Caret }}
+		<tr>
+			<td></td>
+			<td class="col">{{ $.Caret }}</td>
+		</tr>
+		{{- end }}
+		{{- end }}
+	</table>
+	{{- end }}
+	{{- if .Stale }}
+	<p>Showing the last successful render below.</p>
+	{{- end }}
+</div>
+{{- end -}}
+
+{{- define "page" -}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<title>Error - {{ .Path }}</title>
+</head>
+<body style="margin: 0">
+{{ template "overlay" . }}
+</body>
+</html>
+{{- end -}}
+`))
+
+type errorInfo struct {
+	Path      string
+	Err       string
+	File      string
+	Line, Col int
+	Caret     string
+	Excerpt   []highlight.Line
+	Stale     bool
+}
+
+// renderErrorPage renders an HTML page describing err. If the error has a known position in a source
+// file, the page includes a highlighted excerpt of the source around that position.
+//
+// If stale is not nil, it must be the last successful render of the HTML page for path. The error is
+// then shown in a dismissible banner on top of the stale page.
+func renderErrorPage(path string, err error, stale []byte) []byte {
+	info := errorInfo{
+		Path:  path,
+		Err:   err.Error(),
+		Stale: stale != nil,
+	}
+
+	var d *diag.Diagnostic
+	if errors.As(err, &d) && d.File != "" && d.Line > 0 {
+		info.File, info.Line, info.Col = d.File, d.Line, d.Col
+		if src, rerr := os.ReadFile(d.File); rerr == nil {
+			if d.Col > 0 {
+				info.Caret = caret(src, d.Line, d.Col)
+			}
+			if lines, herr := highlight.Highlight(string(src), highlight.LangFromFilename(d.File)); herr == nil {
+				from := max(d.Line-1-excerptContext, 0)
+				to := min(d.Line+excerptContext, len(lines))
+				if from < to {
+					info.Excerpt = lines[from:to]
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if stale == nil {
+		if err := errorPage.ExecuteTemplate(&buf, "page", info); err != nil {
+			return []byte(err.Error())
+		}
+		return buf.Bytes()
+	}
+
+	if err := errorPage.ExecuteTemplate(&buf, "overlay", info); err != nil {
+		return []byte(err.Error())
+	}
+	overlay := buf.Bytes()
+
+	// Insert the overlay right after the opening body tag, or at the very beginning if there's none.
+	pos := 0
+	if i := bytes.Index(stale, []byte("<body")); i >= 0 {
+		if j := bytes.IndexByte(stale[i:], '>'); j >= 0 {
+			pos = i + j + 1
+		}
+	}
+	ret := make([]byte, 0, len(stale)+len(overlay))
+	ret = append(ret, stale[:pos]...)
+	ret = append(ret, overlay...)
+	ret = append(ret, stale[pos:]...)
+	return ret
+}
+
+// caret returns a marker pointing at column col (1-based) of line (1-based) in src. Tabs are
+// preserved, so that the marker lines up with the source line.
+func caret(src []byte, line, col int) string {
+	lines := strings.Split(string(src), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	var sb strings.Builder
+	for i, r := range []rune(lines[line-1]) {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteRune(' ')
+		}
+	}
+	sb.WriteRune('^')
+	return sb.String()
+}
@@ -1,15 +1,61 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+	"weak"
 
 	"flo.znkr.io/generator/site"
 )
 
 type handler struct {
+	opts Options
 	site atomic.Pointer[site.Site]
+
+	mu       sync.Mutex
+	rendered map[string]*rendering // by doc path
+}
+
+// rendering is the cached result of rendering a doc of a site.
+type rendering struct {
+	site    weak.Pointer[site.Site] // doesn't keep replaced sites alive
+	body    []byte
+	gzipped []byte      // nil if the doc isn't compressible
+	header  [][2]string // additional headers
+	hash    string
+	modtime time.Time
+}
+
+func newHandler(opts Options) *handler {
+	return &handler{
+		opts:     opts,
+		rendered: make(map[string]*rendering),
+	}
+}
+
+// setSite replaces the site to serve. Renderings of docs that aren't in s are dropped.
+func (h *handler) setSite(s *site.Site) {
+	h.site.Store(s)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for p := range h.rendered {
+		if s.Doc(p) == nil {
+			delete(h.rendered, p)
+		}
+	}
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -23,7 +69,14 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	doc := s.Doc(req.URL.EscapedPath())
+	doc, redirect := resolve(s, req.URL.Path)
+	if redirect != "" {
+		if req.URL.RawQuery != "" {
+			redirect += "?" + req.URL.RawQuery
+		}
+		http.Redirect(w, req, redirect, http.StatusMovedPermanently)
+		return
+	}
 	if doc == nil {
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusNotFound)
@@ -33,22 +86,159 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", doc.MimeType)
-	if req.Method == http.MethodHead {
+	r, err := h.render(s, doc)
+	if err != nil {
+		var stale []byte
+		if prev := h.lastRendering(doc.Path); prev != nil && isHTML(doc.MimeType) {
+			stale = prev.body
+		}
+		w.Header().Set("Content-Type", "text/html;charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusInternalServerError)
+		if req.Method == http.MethodGet {
+			w.Write(renderErrorPage(req.URL.Path, err, stale))
+		}
+		log.Printf("failed to serve %v: %v", req.URL.EscapedPath(), err)
 		return
 	}
 
+	hdr := w.Header()
+	hdr.Set("Content-Type", doc.MimeType)
+	if h.opts.CacheControl != "" {
+		hdr.Set("Cache-Control", h.opts.CacheControl)
+	}
+	for _, kv := range r.header {
+		hdr.Set(kv[0], kv[1])
+	}
+
+	body, etag := r.body, r.hash
+	if r.gzipped != nil {
+		hdr.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(req) {
+			hdr.Set("Content-Encoding", "gzip")
+			body, etag = r.gzipped, r.hash+"-gzip"
+		}
+	}
+	hdr.Set("ETag", strconv.Quote(etag))
+
+	// ServeContent takes care of conditional requests, range requests, HEAD requests, and sets
+	// Content-Length and Last-Modified.
+	http.ServeContent(w, req, "", r.modtime, bytes.NewReader(body))
+}
+
+// resolve finds the doc for the (percent-decoded) URL path p the same way a static file server
+// would find it in the packed site (see [site.Doc.Filename]): Directories are served from their
+// index.html and requests for a directory without a trailing slash are redirected to the path with
+// a trailing slash.
+//
+// It returns either the doc, a path to redirect to, or neither if there's no doc for p.
+func resolve(s *site.Site, p string) (doc *site.Doc, redirect string) {
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	name := strings.TrimPrefix(path.Clean(p), "/")
+	if strings.HasSuffix(p, "/") {
+		return s.File(path.Join(name, "index.html")), ""
+	}
+	if doc := s.File(name); doc != nil {
+		return doc, ""
+	}
+	if s.File(path.Join(name, "index.html")) != nil {
+		return nil, (&url.URL{Path: "/" + name + "/"}).EscapedPath()
+	}
+	return nil, ""
+}
+
+// render renders doc or returns the cached rendering if doc has been rendered for s before.
+//
+// Renderings are kept across site replacements to determine when the content of a doc last
+// changed, that way Last-Modified stays stable across site reloads that don't touch a doc.
+func (h *handler) render(s *site.Site, doc *site.Doc) (*rendering, error) {
+	prev := h.lastRendering(doc.Path)
+	if prev != nil && prev.site == weak.Make(s) {
+		return prev, nil
+	}
+
 	b, err := s.RenderPage(doc)
 	if err != nil {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(err.Error()))
-		log.Printf("failed to serve %v: %v", req.URL.EscapedPath(), err)
-		return
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+	r := &rendering{
+		site:    weak.Make(s),
+		body:    b,
+		hash:    hex.EncodeToString(sum[:16]),
+		modtime: time.Now().UTC().Truncate(time.Second),
+	}
+	if prev != nil && prev.hash == r.hash {
+		r.modtime = prev.modtime
 	}
+	if ar, ok := doc.Renderer.(*archiveRenderer); ok {
+		r.gzipped = ar.gzip[doc.Path]
+		r.header = ar.header(doc)
+	} else if compressible(doc.MimeType) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		r.gzipped = buf.Bytes()
+	}
+
+	h.mu.Lock()
+	if h.site.Load() == s { // otherwise, doc might have been dropped by setSite already
+		h.rendered[doc.Path] = r
+	}
+	h.mu.Unlock()
+	return r, nil
+}
+
+// lastRendering returns the last successful rendering of the doc with the given path, or nil if
+// it was never rendered successfully.
+func (h *handler) lastRendering(path string) *rendering {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rendered[path]
+}
+
+// acceptsGzip reports whether the client accepts a gzip encoded response.
+func acceptsGzip(req *http.Request) bool {
+	for _, v := range req.Header.Values("Accept-Encoding") {
+		for coding := range strings.SplitSeq(v, ",") {
+			coding, params, _ := strings.Cut(coding, ";")
+			if strings.TrimSpace(coding) != "gzip" {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			f, err := strconv.ParseFloat(q, 64)
+			return err == nil && f > 0
+		}
+	}
+	return false
+}
 
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(b); err != nil {
-		log.Printf("failed to write response: %v", err)
+// isHTML reports whether the mime type is the HTML mime type.
+func isHTML(mimeType string) bool {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	return err == nil && mt == "text/html"
+}
+
+// compressible reports whether it's worth compressing content of the given mime type.
+func compressible(mimeType string) bool {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"):
+		return true
+	case strings.HasSuffix(mt, "+xml"), strings.HasSuffix(mt, "/xml"):
+		return true
+	case mt == "application/javascript", mt == "application/json":
+		return true
 	}
+	return false
 }
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flo.znkr.io/generator/diag"
+	"flo.znkr.io/generator/pack"
+	"flo.znkr.io/generator/site"
+)
+
+type staticRenderer struct{}
+
+type failingRenderer struct{ err error }
+
+func (r failingRenderer) RenderContent(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return nil, r.err
+}
+
+func (r failingRenderer) RenderPage(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return nil, r.err
+}
+
+func (staticRenderer) RenderContent(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return doc.Data, nil
+}
+
+func (staticRenderer) RenderPage(_ *site.Site, doc *site.Doc) ([]byte, error) {
+	return doc.Data, nil
+}
+
+func newTestHandler(t *testing.T, docs ...site.Doc) *handler {
+	t.Helper()
+	s, err := site.New(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(Options{CacheControl: "no-cache"})
+	h.setSite(s)
+	return h
+}
+
+func serve(h http.Handler, method, path string, hdr map[string]string) *http.Response {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range hdr {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func TestHandler_Headers(t *testing.T) {
+	h := newTestHandler(t, site.Doc{
+		Path:     "/a.txt",
+		MimeType: "text/plain;charset=utf-8",
+		Data:     []byte("hello world"),
+		Renderer: staticRenderer{},
+	})
+
+	resp := serve(h, http.MethodGet, "/a.txt", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	for _, key := range []string{"ETag", "Last-Modified", "Content-Length", "Cache-Control"} {
+		if resp.Header.Get(key) == "" {
+			t.Errorf("GET response is missing header %s", key)
+		}
+	}
+	if got, want := resp.Header.Get("Content-Length"), "11"; got != want {
+		t.Errorf("Content-Length = %q, want %q", got, want)
+	}
+
+	head := serve(h, http.MethodHead, "/a.txt", nil)
+	if got, want := head.Header.Get("Content-Length"), "11"; got != want {
+		t.Errorf("HEAD Content-Length = %q, want %q", got, want)
+	}
+	if got, want := head.Header.Get("ETag"), resp.Header.Get("ETag"); got != want {
+		t.Errorf("HEAD ETag = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_Conditional(t *testing.T) {
+	doc := site.Doc{
+		Path:     "/a.txt",
+		MimeType: "text/plain;charset=utf-8",
+		Data:     []byte("hello world"),
+		Renderer: staticRenderer{},
+	}
+	h := newTestHandler(t, doc)
+
+	resp := serve(h, http.MethodGet, "/a.txt", nil)
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+
+	resp = serve(h, http.MethodGet, "/a.txt", map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+	resp = serve(h, http.MethodGet, "/a.txt", map[string]string{"If-Modified-Since": lastModified})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-Modified-Since status = %d, want %d", resp.StatusCode, http.StatusNotModified)
+	}
+
+	// Reloading the site without changing the doc must keep ETag and Last-Modified stable.
+	s, _ := site.New([]site.Doc{doc})
+	h.setSite(s)
+	resp = serve(h, http.MethodGet, "/a.txt", nil)
+	if got := resp.Header.Get("ETag"); got != etag {
+		t.Errorf("ETag after reload = %q, want %q", got, etag)
+	}
+	if got := resp.Header.Get("Last-Modified"); got != lastModified {
+		t.Errorf("Last-Modified after reload = %q, want %q", got, lastModified)
+	}
+
+	// Changing the doc must change the ETag.
+	doc.Data = []byte("hello there")
+	s, _ = site.New([]site.Doc{doc})
+	h.setSite(s)
+	resp = serve(h, http.MethodGet, "/a.txt", map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("If-None-Match after change status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// Removing the doc must drop its rendering.
+	s, _ = site.New(nil)
+	h.setSite(s)
+	if r := h.lastRendering(doc.Path); r != nil {
+		t.Errorf("rendering of %s kept after the doc was removed", doc.Path)
+	}
+}
+
+func TestHandler_Range(t *testing.T) {
+	h := newTestHandler(t, site.Doc{
+		Path:     "/a.png",
+		MimeType: "image/png",
+		Data:     []byte("0123456789"),
+		Renderer: staticRenderer{},
+	})
+
+	resp := serve(h, http.MethodGet, "/a.png", map[string]string{"Range": "bytes=2-5"})
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusPartialContent)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	if got, want := string(b), "2345"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_Gzip(t *testing.T) {
+	h := newTestHandler(t,
+		site.Doc{
+			Path:     "/a.txt",
+			MimeType: "text/plain;charset=utf-8",
+			Data:     []byte("hello world"),
+			Renderer: staticRenderer{},
+		},
+		site.Doc{
+			Path:     "/a.png",
+			MimeType: "image/png",
+			Data:     []byte("0123456789"),
+			Renderer: staticRenderer{},
+		},
+	)
+
+	plain := serve(h, http.MethodGet, "/a.txt", nil)
+	resp := serve(h, http.MethodGet, "/a.txt", map[string]string{"Accept-Encoding": "br, gzip"})
+	if got, want := resp.Header.Get("Content-Encoding"), "gzip"; got != want {
+		t.Fatalf("Content-Encoding = %q, want %q", got, want)
+	}
+	if resp.Header.Get("ETag") == plain.Header.Get("ETag") {
+		t.Errorf("gzip response shares ETag with identity response")
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(zr)
+	if got, want := string(b), "hello world"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+
+	resp = serve(h, http.MethodGet, "/a.txt", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding with gzip;q=0 = %q, want none", got)
+	}
+
+	resp = serve(h, http.MethodGet, "/a.png", map[string]string{"Accept-Encoding": "gzip"})
+	if got := resp.Header.Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding for image = %q, want none", got)
+	}
+}
+
+func TestHandler_MatchesPack(t *testing.T) {
+	var docs []site.Doc
+	for _, d := range []struct{ path, mimeType string }{
+		{"/", "text/html;charset=utf-8"},
+		{"/about", "text/html;charset=utf-8"},
+		{"/diff", "text/html;charset=utf-8"},
+		{"/diff/topology.png", "image/png"},
+		{"/diff/with space.txt", "text/plain;charset=utf-8"},
+		{"/nested/deeper/article", "text/html;charset=utf-8"},
+	} {
+		docs = append(docs, site.Doc{
+			Path:     d.path,
+			MimeType: d.mimeType,
+			Data:     []byte("content of " + d.path),
+			Renderer: staticRenderer{},
+		})
+	}
+	h := newTestHandler(t, docs...)
+
+	filename := filepath.Join(t.TempDir(), "site.tar")
+	if err := pack.Pack(filename, h.site.Load(), pack.Options{}); err != nil {
+		t.Fatalf("packing site: %v", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	packed := make(map[string]string)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packed[strings.TrimPrefix(hdr.Name, "./")] = string(b)
+	}
+	if got, want := len(packed), len(docs); got != want {
+		t.Fatalf("got %d packed files, want %d", got, want)
+	}
+
+	// Every packed file must be served from its path in the archive.
+	for name, want := range packed {
+		u := "/" + (&url.URL{Path: name}).EscapedPath()
+		resp := serve(h, http.MethodGet, u, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d", u, resp.StatusCode, http.StatusOK)
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if got := string(b); got != want {
+			t.Errorf("GET %s: body = %q, want %q", u, got, want)
+		}
+		if dir, ok := strings.CutSuffix(u, "index.html"); ok {
+			resp := serve(h, http.MethodGet, dir, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("GET %s: status = %d, want %d", dir, resp.StatusCode, http.StatusOK)
+			}
+		}
+	}
+
+	// Every doc must be reachable from its path, possibly after a redirect.
+	for _, d := range docs {
+		u := (&url.URL{Path: d.Path}).EscapedPath()
+		resp := serve(h, http.MethodGet, u, nil)
+		if resp.StatusCode == http.StatusMovedPermanently {
+			loc := resp.Header.Get("Location")
+			if loc != u+"/" {
+				t.Errorf("GET %s: redirect to %q, want %q", u, loc, u+"/")
+			}
+			resp = serve(h, http.MethodGet, loc, nil)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d", u, resp.StatusCode, http.StatusOK)
+			continue
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if got, want := string(b), packed[d.Filename()]; got != want {
+			t.Errorf("GET %s: body = %q, want %q", u, got, want)
+		}
+	}
+
+	resp := serve(h, http.MethodGet, "/does-not-exist/", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /does-not-exist/: status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestHandler_ErrorPage(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "index.md")
+	if err := os.WriteFile(src, []byte("# Title\n\n<!--#include-snippet file=x -->\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	serr := diag.Errorf(src, 3, 27, "unexpected 'x'")
+
+	doc := site.Doc{
+		Path:     "/a",
+		MimeType: "text/html;charset=utf-8",
+		Data:     []byte("<html><body><p>good</p></body></html>"),
+		Renderer: failingRenderer{fmt.Errorf("rendering: %w", serr)},
+	}
+	h := newTestHandler(t, doc)
+
+	resp := serve(h, http.MethodGet, "/a/", nil)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{src + ":3:27", "-snippet file=x", `class="error"`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("error page doesn't contain %q:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "good") {
+		t.Errorf("error page contains content that was never rendered successfully")
+	}
+
+	// Render successfully once, then fail again. The error page now must contain the stale render.
+	doc.Renderer = staticRenderer{}
+	s, _ := site.New([]site.Doc{doc})
+	h.setSite(s)
+	if resp := serve(h, http.MethodGet, "/a/", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	doc.Renderer = failingRenderer{serr}
+	s, _ = site.New([]site.Doc{doc})
+	h.setSite(s)
+	resp = serve(h, http.MethodGet, "/a/", nil)
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusInternalServerError)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	if !strings.Contains(string(b), `<body><style>`) || !strings.Contains(string(b), "<p>good</p>") {
+		t.Errorf("error page doesn't show the banner on top of the last good render:\n%s", b)
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -16,26 +17,40 @@ type Server struct {
 	errc    chan error
 }
 
+// Options configure a server.
+type Options struct {
+	// CacheControl is sent as the Cache-Control header with every successful response. No
+	// Cache-Control header is sent if empty.
+	CacheControl string
+}
+
 // Run creates a new server anc runs it in a new goroutine.
-func Run(addr string, site *site.Site) (*Server, error) {
+func Run(addr string, site *site.Site, opts Options) (*Server, error) {
 	l, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("starting HTTP server: %v", err)
 	}
 
-	h := &handler{}
-	h.site.Store(site)
+	h := newHandler(opts)
+	h.setSite(site)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("/", h)
 
 	s := &Server{
 		http: &http.Server{
-			Handler: h,
+			Handler: mux,
 		},
 		handler: h,
-		errc:    make(chan error),
+		errc:    make(chan error, 1),
 	}
 
 	go func() {
-		if err := s.http.Serve(l); err != nil {
+		if err := s.http.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.errc <- err
 		}
 	}()
@@ -45,7 +60,7 @@ func Run(addr string, site *site.Site) (*Server, error) {
 
 // ReplaceSite replaces the site to serve with the one provided.
 func (s *Server) ReplaceSite(site *site.Site) {
-	s.handler.site.Store(site)
+	s.handler.setSite(site)
 }
 
 // Shutdown gracefully stops the sever.
@@ -3,25 +3,46 @@ package site
 import (
 	"cmp"
 	"fmt"
+	"mime"
+	"path"
 	"slices"
+	"strings"
 	"time"
 )
 
 // Site is an in-memory representation of the to be generated site.
 type Site struct {
-	docs map[string]Doc
+	docs  map[string]Doc
+	files map[string]string // filename to doc path
 }
 
 // Doc is a single document of the site, that is anything that can be served as a static file.
 type Doc struct {
 	Path     string
 	Source   string
+	DataLine int // line in Source at which Data starts, 0 if unknown
 	MimeType string
 	Meta     *Metadata
 	Data     []byte
 	Renderer Renderer
 }
 
+// Filename returns the name of the file d is written to when the site is packed. The name is
+// relative to the root of the site.
+//
+// HTML docs without an extension are stored as index.html in a directory of the same name as the
+// doc, so that they can be served from the doc path by any static file server.
+func (d *Doc) Filename() string {
+	if d.Path == "/" {
+		return "index.html"
+	}
+	name := d.Path
+	if mt, _, err := mime.ParseMediaType(d.MimeType); err == nil && mt == "text/html" && path.Ext(name) == "" {
+		name += "/index.html"
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
 type Renderer interface {
 	RenderContent(s *Site, doc *Doc) ([]byte, error)
 	RenderPage(s *Site, doc *Doc) ([]byte, error)
@@ -42,13 +63,19 @@ type Metadata struct {
 // If there are multiple docs for the same path, New returns an error.
 func New(docs []Doc) (*Site, error) {
 	s := &Site{
-		docs: make(map[string]Doc),
+		docs:  make(map[string]Doc),
+		files: make(map[string]string),
 	}
 	for _, d := range docs {
 		if _, exists := s.docs[d.Path]; exists {
 			return nil, fmt.Errorf("duplicate doc for path %q", d.Path)
 		}
+		name := d.Filename()
+		if other, exists := s.files[name]; exists {
+			return nil, fmt.Errorf("docs for paths %q and %q are both stored as %q", other, d.Path, name)
+		}
 		s.docs[d.Path] = d
+		s.files[name] = d.Path
 	}
 	return s, nil
 }
@@ -62,6 +89,16 @@ func (s *Site) Doc(path string) *Doc {
 	return &d
 }
 
+// File returns the document stored in the file with the given name when the site is packed, or nil
+// if there is no such file. See [Doc.Filename].
+func (s *Site) File(name string) *Doc {
+	p, ok := s.files[name]
+	if !ok {
+		return nil
+	}
+	return s.Doc(p)
+}
+
 func (s *Site) Articles() []*Doc {
 	var ret []*Doc
 	for _, d := range s.docs {
@@ -90,7 +127,7 @@ func (s *Site) AllDocs() []*Doc {
 func (s *Site) RenderContent(d *Doc) ([]byte, error) {
 	b, err := d.Renderer.RenderContent(s, d)
 	if err != nil {
-		return nil, fmt.Errorf("rendering content of %s: %v", d.Path, err)
+		return nil, fmt.Errorf("rendering content of %s: %w", d.Path, err)
 	}
 	return b, nil
 }
@@ -99,7 +136,7 @@ func (s *Site) RenderContent(d *Doc) ([]byte, error) {
 func (s *Site) RenderPage(d *Doc) ([]byte, error) {
 	b, err := d.Renderer.RenderPage(s, d)
 	if err != nil {
-		return nil, fmt.Errorf("rendering page for %s: %v", d.Path, err)
+		return nil, fmt.Errorf("rendering page for %s: %w", d.Path, err)
 	}
 	return b, nil
 }
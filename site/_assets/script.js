@@ -3,7 +3,17 @@ document.addEventListener("DOMContentLoaded", main)
 function main() {
     new Scroller()
     for (const table of document.querySelectorAll("table.code-snippet.diff")) {
-        new DiffTable(table)
+        if (table.dataset.folding != "static") {
+            // Diffs parsed from patches only contain hunks and can't be unfolded, other diffs show
+            // the full file on purpose.
+            new DiffTable(table)
+        }
+    }
+    for (const table of document.querySelectorAll("table.code-snippet.diff-split")) {
+        new SplitDiffTable(table)
+    }
+    for (const steps of document.querySelectorAll("div.steps")) {
+        new Stepper(steps)
     }
 }
 
@@ -53,15 +63,73 @@ class Scroller {
     }
 }
 
+// Stepper shows one step of a stepper at a time. Without JavaScript, all steps are shown one after
+// another and the navigation links jump between them.
+class Stepper {
+    #steps
+
+    constructor(container) {
+        this.#steps = Array.from(container.querySelectorAll(":scope > section.step"))
+        container.classList.add("interactive")
+        for (const link of container.querySelectorAll(".step-nav a")) {
+            link.addEventListener("click", (event) => {
+                event.preventDefault()
+                this.#show(link.getAttribute("href").substring(1))
+            })
+        }
+        let hash = window.location.hash.substring(1)
+        this.#show(this.#steps.some(step => step.id == hash) ? hash : this.#steps[0].id)
+    }
+
+    #show(id) {
+        for (const step of this.#steps) {
+            step.classList.toggle("active", step.id == id)
+        }
+    }
+}
+
+// SplitDiffTable turns the folds of a side-by-side diff into controls that show the folded lines.
+// Without JavaScript, folded lines stay hidden.
+class SplitDiffTable {
+    constructor(table) {
+        for (const fold of table.querySelectorAll(":scope > tbody.fold")) {
+            let folded = fold.nextElementSibling
+            if (!folded?.classList.contains("folded")) {
+                continue
+            }
+            let button = document.createElement("button")
+            button.setAttribute("title", "Unfold")
+            button.classList.add("fold-button")
+            button.classList.add("unfold")
+            button.onclick = (event) => {
+                folded.hidden = false
+                fold.remove()
+            }
+            let row = fold.rows[0]
+            row.classList.add("ctrl")
+            let cell = row.cells[0]
+            cell.textContent = ""
+            cell.classList.add("fold-ctrl")
+            cell.appendChild(button)
+        }
+    }
+}
+
 class DiffTable {
-    static #maxContext = 3
     static #maxUnfold = 20
 
     #table
+    #maxContext = 3
 
     constructor(table) {
         this.#table = table
 
+        if (table.dataset.context !== undefined) {
+            // The diff was folded server-side for readers without JavaScript, take over folding.
+            this.#maxContext = Number(table.dataset.context)
+            DiffTable.#unfoldServerSide(table)
+        }
+
         var prev = 0
         for (let i = 0; i < table.rows.length; i++) {
             let row = table.rows[i]
@@ -82,10 +150,10 @@ class DiffTable {
         for (let group of DiffTable.#groupMatches(table)) {
             let maxContextTotal = 0
             if (!group.isStart) {
-                maxContextTotal += DiffTable.#maxContext
+                maxContextTotal += this.#maxContext
             }
             if (!group.isEnd) {
-                maxContextTotal += DiffTable.#maxContext
+                maxContextTotal += this.#maxContext
             }
             if (group.last.rowIndex - group.first.rowIndex < maxContextTotal + 1) {
                 // Don't hide if the number of hidden rows is smaller than context rows plus 1 row
@@ -98,12 +166,12 @@ class DiffTable {
             // or next edit if both diffed file starts or ends with the same rows. In those cases, we
             // don't want context and instead hide all matches.
             if (!group.isStart) {
-                for (let i = 0; i < DiffTable.#maxContext; i++) {
+                for (let i = 0; i < this.#maxContext; i++) {
                     group.first = group.first.nextSibling
                 }
             }
             if (!group.isEnd) {
-                for (let i = 0; i < DiffTable.#maxContext; i++) {
+                for (let i = 0; i < this.#maxContext; i++) {
                     group.last = group.last.previousSibling
                 }
             }
@@ -113,6 +181,20 @@ class DiffTable {
         }
     }
 
+    // Replaces the server-side folds and hunk headers with a single body containing all rows.
+    static #unfoldServerSide(table) {
+        let body = document.createElement("tbody")
+        for (let row of Array.from(table.rows)) {
+            if (row.dataset.op !== undefined || row.classList.contains("note")) {
+                body.appendChild(row)
+            }
+        }
+        for (let old of Array.from(table.tBodies)) {
+            old.remove()
+        }
+        table.appendChild(body)
+    }
+
     static #groupMatches(table) {
         let groups = []
         let first = null
@@ -120,7 +202,12 @@ class DiffTable {
         let isStart = true
         for (let i = 0; i < table.rows.length; i++) {
             let row = table.rows[i]
-            switch (row.dataset.op) {
+            let op = row.dataset.op
+            if (op == "match" && row.nextElementSibling?.classList.contains("note")) {
+                // Lines with notes are never hidden, they're treated like edits.
+                op = "annotated"
+            }
+            switch (op) {
                 case "match":
                     if (first == null) {
                         first = row
@@ -128,6 +215,7 @@ class DiffTable {
                     break
                 case "delete":
                 case "insert":
+                case "annotated":
                     if (first != null) {
                         // i must be > 0 because we always start with first == null
                         let group = {